@@ -0,0 +1,424 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// command runs a subcommand with the arguments that follow its name
+// and returns the output to print.
+type command func(t *toggler, args []string) (string, error)
+
+// commands are the subcommands, keyed by name. Any other argument is
+// a toggle target.
+var commands = map[string]command{
+	"arrangement":   (*toggler).arrangementCommand,
+	"broadcast":     (*toggler).broadcastCommand,
+	"close":         (*toggler).closeCommand,
+	"color-presets": (*toggler).colorPresetsCommand,
+	"copy":          (*toggler).copyCommand,
+	"doctor":        (*toggler).doctorCommand,
+	"restart":       (*toggler).restartCommand,
+	"send":          (*toggler).sendCommand,
+}
+
+// isCommand reports whether args start with a subcommand.
+func isCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	_, ok := commands[args[0]]
+	return ok
+}
+
+// runCommand runs the subcommand named by args[0].
+func (t *toggler) runCommand(args []string) (string, error) {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return "", fmt.Errorf("unknown command %q", args[0])
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.status.invalidate()
+	return cmd(t, args[1:])
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors
+// instead of exiting, since commands also run inside the daemon.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// arrangementCommand saves, restores or lists saved arrangements:
+//
+//	arrangement save [--window] <name>
+//	arrangement restore [--window] <name>
+//	arrangement list
+//
+// With --window only the tabs of the current window are saved, or the
+// arrangement is restored as tabs in the current window.
+func (t *toggler) arrangementCommand(args []string) (string, error) {
+	usage := fmt.Errorf("usage: arrangement save|restore [--window] <name> | arrangement list")
+	if len(args) == 0 {
+		return "", usage
+	}
+
+	if args[0] == "list" {
+		names, err := t.app.ListArrangements()
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "", nil
+		}
+		return strings.Join(names, "\n") + "\n", nil
+	}
+
+	fs := newFlagSet("arrangement " + args[0])
+	window := fs.Bool("window", false, "use the current window only")
+	if err := fs.Parse(args[1:]); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() != 1 {
+		return "", usage
+	}
+	name := fs.Arg(0)
+
+	if !*window {
+		switch args[0] {
+		case "save":
+			return "", t.app.SaveArrangement(name)
+		case "restore":
+			return "", t.app.RestoreArrangement(name)
+		}
+		return "", usage
+	}
+
+	focus, err := t.app.CurrentFocus()
+	if err != nil {
+		return "", err
+	}
+	if focus.Window == nil {
+		return "", fmt.Errorf("there is no current window")
+	}
+	switch args[0] {
+	case "save":
+		return "", focus.Window.SaveArrangement(name)
+	case "restore":
+		return "", focus.Window.RestoreArrangement(name)
+	}
+	return "", usage
+}
+
+// broadcastCommand sends keyboard input typed into one of a target's
+// sessions to all of them, or turns broadcasting off for them:
+//
+//	broadcast <target>
+//	broadcast --off <target>
+//
+// A broadcast domain can't span windows, so the target's sessions get
+// a domain per window. Domains of other sessions are left alone.
+func (t *toggler) broadcastCommand(args []string) (string, error) {
+	usage := fmt.Errorf("usage: broadcast [--off] <target>")
+	fs := newFlagSet("broadcast")
+	off := fs.Bool("off", false, "turn off broadcasting")
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() != 1 {
+		return "", usage
+	}
+	name := fs.Arg(0)
+
+	sessions, windowOf, err := findSessions(t.app, t.config.target(name).Match)
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "", fmt.Errorf("no sessions match %q", name)
+	}
+
+	matched := map[string]bool{}
+	for _, s := range sessions {
+		matched[s.GetSessionID()] = true
+	}
+
+	// domains must be disjoint, so take the target's sessions out of
+	// the existing ones
+	existing, err := t.app.BroadcastDomains()
+	if err != nil {
+		return "", err
+	}
+	domains := []iterm2.BroadcastDomain{}
+	for _, d := range existing {
+		kept := iterm2.BroadcastDomain{}
+		for _, s := range d {
+			if !matched[s.GetSessionID()] {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) > 0 {
+			domains = append(domains, kept)
+		}
+	}
+
+	if *off {
+		err = t.app.SetBroadcastDomains(domains)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("stopped broadcasting to %d sessions\n", len(sessions)), nil
+	}
+
+	windows := []string{}
+	perWindow := map[string]iterm2.BroadcastDomain{}
+	for _, s := range sessions {
+		id := windowOf[s.GetSessionID()].GetWindowID()
+		if _, ok := perWindow[id]; !ok {
+			windows = append(windows, id)
+		}
+		perWindow[id] = append(perWindow[id], s)
+	}
+	for _, id := range windows {
+		domains = append(domains, perWindow[id])
+	}
+
+	err = t.app.SetBroadcastDomains(domains)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("broadcasting to %d sessions in %d windows\n", len(sessions), len(windows)), nil
+}
+
+// sendCommand types text into a target's most recently used session,
+// or into all of its sessions, without changing focus:
+//
+//	send [--newline] [--all] <target> <text>...
+//
+// It reports the status of each session it sent text to.
+func (t *toggler) sendCommand(args []string) (string, error) {
+	usage := fmt.Errorf("usage: send [--newline] [--all] <target> <text>...")
+	fs := newFlagSet("send")
+	newline := fs.Bool("newline", false, "end the text with a newline")
+	all := fs.Bool("all", false, "send to all matching sessions")
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() < 2 {
+		return "", usage
+	}
+	name := fs.Arg(0)
+	text := strings.Join(fs.Args()[1:], " ")
+	if *newline {
+		text += "\n"
+	}
+
+	sessions, err := t.targetSessions(name, *all)
+	if err != nil {
+		return "", err
+	}
+	return eachSession(sessions, "send text to", func(s *iterm2.Session) error {
+		return s.SendText(text)
+	})
+}
+
+// closeCommand closes a target's most recently used session, or all of
+// its sessions:
+//
+//	close [--force] [--all] <target>
+//
+// Without --force iTerm2 may ask for confirmation first.
+func (t *toggler) closeCommand(args []string) (string, error) {
+	usage := fmt.Errorf("usage: close [--force] [--all] <target>")
+	fs := newFlagSet("close")
+	force := fs.Bool("force", false, "don't ask for confirmation")
+	all := fs.Bool("all", false, "close all matching sessions")
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() != 1 {
+		return "", usage
+	}
+
+	sessions, err := t.targetSessions(fs.Arg(0), *all)
+	if err != nil {
+		return "", err
+	}
+	return eachSession(sessions, "close", func(s *iterm2.Session) error {
+		return s.Close(*force)
+	})
+}
+
+// restartCommand restarts the program of a target's most recently used
+// session, or of all of its sessions:
+//
+//	restart [--exited] [--all] <target>
+//
+// With --exited, sessions that are still running are skipped.
+func (t *toggler) restartCommand(args []string) (string, error) {
+	usage := fmt.Errorf("usage: restart [--exited] [--all] <target>")
+	fs := newFlagSet("restart")
+	exited := fs.Bool("exited", false, "only restart sessions that have exited")
+	all := fs.Bool("all", false, "restart all matching sessions")
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() != 1 {
+		return "", usage
+	}
+
+	sessions, err := t.targetSessions(fs.Arg(0), *all)
+	if err != nil {
+		return "", err
+	}
+	return eachSession(sessions, "restart", func(s *iterm2.Session) error {
+		err := s.Restart(*exited)
+		if *exited && errors.Is(err, iterm2.ErrNotRestartable) {
+			return nil
+		}
+		return err
+	})
+}
+
+// colorPresetsCommand lists the color presets, for use in a target's
+// highlight:
+//
+//	color-presets
+func (t *toggler) colorPresetsCommand(args []string) (string, error) {
+	if len(args) != 0 {
+		return "", fmt.Errorf("usage: color-presets")
+	}
+	names, err := t.app.ListColorPresets()
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	return strings.Join(names, "\n") + "\n", nil
+}
+
+// copyCommand prints the last lines, or the selected text, of a
+// target's most recently used session:
+//
+//	copy <target> [--lines N]
+//
+// Blank lines at the bottom of the screen don't count as lines.
+func (t *toggler) copyCommand(args []string) (string, error) {
+	usage := fmt.Errorf("usage: copy <target> [--lines N]")
+	fs := newFlagSet("copy")
+	lines := fs.Int("lines", 0, "copy the last N lines instead of the selection")
+	// flags may come before or after the target
+	if err := fs.Parse(args); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() < 1 {
+		return "", usage
+	}
+	name := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", fmt.Errorf("%s: %w", usage, err)
+	}
+	if fs.NArg() != 0 || *lines < 0 {
+		return "", usage
+	}
+
+	sessions, err := t.targetSessions(name, false)
+	if err != nil {
+		return "", err
+	}
+	s := sessions[0]
+
+	var text string
+	if *lines > 0 {
+		text, err = lastLines(s, *lines)
+	} else {
+		text, err = s.SelectedText()
+		if err == nil && text == "" {
+			err = fmt.Errorf("nothing is selected in session %s", s.GetSessionID())
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text, nil
+}
+
+// lastLines returns the last n lines of the session's buffer, leaving
+// out the blank lines below the cursor.
+func lastLines(s *iterm2.Session, n int) (string, error) {
+	size, err := s.GridSize()
+	if err != nil {
+		return "", err
+	}
+	lines, err := s.GetBuffer(iterm2.LineRange{Trailing: n + int(size.Height)})
+	if err != nil {
+		return "", err
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1].Text) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return joinLines(lines), nil
+}
+
+// targetSessions returns the most recently used session matching the
+// named target, or all of them.
+func (t *toggler) targetSessions(name string, all bool) ([]*iterm2.Session, error) {
+	sessions, _, err := findSessions(t.app, t.config.target(name).Match)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("no sessions match %q", name)
+	}
+	if all {
+		return sessions, nil
+	}
+
+	// the focused session is the most recently used one, even when the
+	// daemon isn't tracking focus
+	focus, err := t.app.CurrentFocus()
+	if err != nil {
+		return nil, err
+	}
+	if focus.Session != nil {
+		t.recent.touch(focus.Session.GetSessionID())
+	}
+	return []*iterm2.Session{t.recent.pick(sessions)}, nil
+}
+
+// eachSession calls fn for each session and reports the status of
+// each. It fails if fn failed for any of them.
+func eachSession(sessions []*iterm2.Session, verb string, fn func(s *iterm2.Session) error) (string, error) {
+	var (
+		b      strings.Builder
+		failed int
+	)
+	for _, s := range sessions {
+		err := fn(s)
+		if err != nil {
+			failed++
+			fmt.Fprintf(&b, "%s: %s\n", s.GetSessionID(), err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: ok\n", s.GetSessionID())
+	}
+	if failed > 0 {
+		return b.String(), fmt.Errorf("could not %s %d of %d sessions", verb, failed, len(sessions))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// What to do when the toggle is triggered while one of the target's
+// sessions is already focused and iTerm2 is the frontmost app.
+const (
+	// onFocusedCycle activates the next matching session.
+	onFocusedCycle = "cycle"
+	// onFocusedToggleBack reactivates the app that was frontmost before
+	// the toggle brought iTerm2 forward.
+	onFocusedToggleBack = "toggle-back"
+	// onFocusedHide hides iTerm2.
+	onFocusedHide = "hide"
+)
+
+// config is read from a JSON file. Arguments that have no entry in
+// Targets use the default target settings.
+type config struct {
+	Targets map[string]target `json:"targets"`
+	// Keys bind keys pressed in iTerm2 to toggles.
+	Keys []keyBinding `json:"keys"`
+}
+
+// target holds the settings for one toggle argument.
+type target struct {
+	// OnFocused is one of "cycle" (default), "toggle-back" or "hide".
+	OnFocused string `json:"on_focused"`
+	// Activate controls how the matching session is brought forward.
+	Activate activation `json:"activate"`
+	// Match selects the sessions of the target. By default sessions
+	// whose process title contains the toggle argument match.
+	Match matcher `json:"match"`
+	// Launch, when set, creates a session for the target when no
+	// session matches.
+	Launch *launch `json:"launch"`
+	// Arrangement, when set, is the saved arrangement that is restored
+	// when no session matches. It can't be combined with Launch.
+	Arrangement string `json:"arrangement"`
+	// Frame, when set, moves and resizes the window of the activated
+	// session, for example {"origin": {"x": 0, "y": 0}, "size":
+	// {"width": 1024, "height": 768}}.
+	Frame *iterm2.Frame `json:"frame"`
+	// Highlight, when set, changes the colors of the activated session.
+	Highlight *highlight `json:"highlight"`
+	// Flash, when set, shows a badge or sets a mark on the activated
+	// session.
+	Flash *flash `json:"flash"`
+	// Restart restarts the activated session's program if it has
+	// exited, so a dead REPL comes back with the same toggle.
+	Restart bool `json:"restart"`
+}
+
+// launch describes how to create a session for a target.
+type launch struct {
+	// Profile is the name of the profile to use. The default profile
+	// is used when empty.
+	Profile string `json:"profile"`
+	// Command overrides the profile's command.
+	Command string `json:"command"`
+	// Tab creates the session in a new tab of the current window
+	// instead of in a new window.
+	Tab bool `json:"tab"`
+	// Tmux creates the session as a window of the target's tmux
+	// session, which must be connected in integration mode, and names
+	// it after the target's tmux window. Profile and Title don't apply.
+	Tmux bool `json:"tmux"`
+	// Title is the title of the launched tab. It defaults to the name
+	// of the target; set it to "" to keep the profile's title.
+	Title *string `json:"title"`
+}
+
+// activation mirrors iterm2.ActivateOptions. Unset fields use the
+// defaults from activateOptions.
+type activation struct {
+	SelectTab         *bool `json:"select_tab"`
+	OrderWindowFront  *bool `json:"order_window_front"`
+	ActivateApp       *bool `json:"activate_app"`
+	RaiseAllWindows   *bool `json:"raise_all_windows"`
+	IgnoringOtherApps *bool `json:"ignoring_other_apps"`
+}
+
+// activateOptions returns the options for Session.ActivateWithOptions.
+// By default the session's tab is selected, its window is ordered
+// front and iTerm2 is activated, even if another app is active.
+func (a activation) activateOptions() iterm2.ActivateOptions {
+	return iterm2.ActivateOptions{
+		SelectTab:         boolOr(a.SelectTab, true),
+		OrderWindowFront:  boolOr(a.OrderWindowFront, true),
+		ActivateApp:       boolOr(a.ActivateApp, true),
+		RaiseAllWindows:   boolOr(a.RaiseAllWindows, false),
+		IgnoringOtherApps: boolOr(a.IgnoringOtherApps, true),
+	}
+}
+
+func boolOr(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+// configPath returns the location of the config file. It can be
+// overridden with the ITERM2_TOGGLE_CONFIG environment variable.
+func configPath() (string, error) {
+	if p := os.Getenv("ITERM2_TOGGLE_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "iterm2-toggle", "config.json"), nil
+}
+
+// loadConfig reads the config file. A missing file is not an error,
+// it results in an empty config.
+func loadConfig(p string) (*config, error) {
+	cfg := &config{Targets: map[string]target{}}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Read config file (%s) error: %s", p, err)
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("Parse config file (%s) error: %s", p, err)
+	}
+	for name, t := range cfg.Targets {
+		switch t.OnFocused {
+		case "", onFocusedCycle, onFocusedToggleBack, onFocusedHide:
+		default:
+			return nil, fmt.Errorf("target %q: unknown on_focused value %q", name, t.OnFocused)
+		}
+		if t.Launch != nil && t.Arrangement != "" {
+			return nil, fmt.Errorf("target %q: launch and arrangement can't be combined", name)
+		}
+		if t.Launch != nil && t.Launch.Tmux && t.Match.TmuxWindow == "" {
+			return nil, fmt.Errorf("target %q: a tmux launch requires match.tmux_window", name)
+		}
+		if t.Highlight != nil {
+			if err := t.Highlight.compile(); err != nil {
+				return nil, fmt.Errorf("target %q: %s", name, err)
+			}
+		}
+		if t.Flash != nil {
+			if err := t.Flash.compile(); err != nil {
+				return nil, fmt.Errorf("target %q: %s", name, err)
+			}
+		}
+		if err := t.Match.compile(); err != nil {
+			return nil, fmt.Errorf("target %q: %s", name, err)
+		}
+		cfg.Targets[name] = t
+	}
+	for i, b := range cfg.Keys {
+		if _, err := b.pattern(); err != nil {
+			return nil, fmt.Errorf("key %d: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// target returns the settings for arg, with defaults filled in.
+func (c *config) target(arg string) target {
+	t := c.Targets[arg]
+	if t.OnFocused == "" {
+		t.OnFocused = onFocusedCycle
+	}
+	if t.Match.empty() {
+		t.Match.ProcessTitle = arg
+		t.Match.orTag = arg
+	}
+	return t
+}
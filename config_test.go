@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestConfigTarget(t *testing.T) {
+	c := &config{Targets: map[string]target{
+		"notes": {OnFocused: onFocusedHide, Match: matcher{Tag: "notes"}},
+		"logs":  {OnFocused: onFocusedToggleBack},
+	}}
+
+	tests := []struct {
+		arg       string
+		onFocused string
+		match     matcher
+	}{
+		{"vim", onFocusedCycle, matcher{ProcessTitle: "vim", orTag: "vim"}},
+		{"notes", onFocusedHide, matcher{Tag: "notes"}},
+		{"logs", onFocusedToggleBack, matcher{ProcessTitle: "logs", orTag: "logs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got := c.target(tt.arg)
+			if got.OnFocused != tt.onFocused {
+				t.Errorf("OnFocused = %q, want %q", got.OnFocused, tt.onFocused)
+			}
+			if got.Match.ProcessTitle != tt.match.ProcessTitle || got.Match.Tag != tt.match.Tag || got.Match.orTag != tt.match.orTag {
+				t.Errorf("Match = %+v, want %+v", got.Match, tt.match)
+			}
+		})
+	}
+
+	// the configured target itself must not be changed
+	if m := c.Targets["logs"].Match; m.ProcessTitle != "" || m.orTag != "" {
+		t.Errorf("target() changed the config: %+v", m)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// doctorReport collects the findings of the doctor command.
+type doctorReport struct {
+	b      strings.Builder
+	errors int
+}
+
+func (r *doctorReport) ok(format string, args ...any) {
+	fmt.Fprintf(&r.b, "ok: "+format+"\n", args...)
+}
+
+func (r *doctorReport) warn(format string, args ...any) {
+	fmt.Fprintf(&r.b, "warning: "+format+"\n", args...)
+}
+
+func (r *doctorReport) fail(format string, args ...any) {
+	r.errors++
+	fmt.Fprintf(&r.b, "error: "+format+"\n", args...)
+}
+
+// doctorCommand checks iTerm2's preferences and the config for
+// problems that break the toggle:
+//
+//	doctor
+//
+// It fails if any errors were found; warnings only point out settings
+// that may get in the way.
+func (t *toggler) doctorCommand(args []string) (string, error) {
+	if len(args) != 0 {
+		return "", fmt.Errorf("usage: doctor")
+	}
+	return t.doctor(nil)
+}
+
+// doctor runs the checks of the doctor command. connErr is the error
+// connecting to iTerm2, if any, in which case t has no app and only
+// the checks that don't need iTerm2 are run.
+func (t *toggler) doctor(connErr error) (string, error) {
+	r := &doctorReport{}
+
+	if connErr != nil {
+		r.fail("could not connect to iTerm2, is the Python API enabled in Preferences > General > Magic? %s", connErr)
+		doctorSystemEvents(r)
+		return r.b.String(), fmt.Errorf("found %d problems", r.errors)
+	}
+	r.ok("connected to iTerm2's Python API")
+
+	prefs, err := t.app.Preferences("FocusFollowsMouse", "QuitWhenAllWindowsClosed", "Hotkey")
+	if err != nil {
+		r.fail("could not read preferences: %s", err)
+	} else {
+		if ffm, _ := prefs["FocusFollowsMouse"].Bool(); ffm {
+			r.warn("focus follows mouse is on, moving the mouse can focus another session than the toggled one")
+		}
+		if quit, _ := prefs["QuitWhenAllWindowsClosed"].Bool(); quit {
+			r.warn("iTerm2 quits when all windows are closed, closing the last session stops the daemon")
+		}
+		if hotkey, _ := prefs["Hotkey"].Bool(); hotkey {
+			r.warn("iTerm2's system-wide hotkey is on, make sure it differs from the toggle's hotkeys")
+		}
+	}
+
+	doctorSystemEvents(r)
+	t.doctorTargets(r)
+
+	if r.errors > 0 {
+		return r.b.String(), fmt.Errorf("found %d problems", r.errors)
+	}
+	return r.b.String(), nil
+}
+
+// doctorSystemEvents checks that the frontmost app can be found.
+func doctorSystemEvents(r *doctorReport) {
+	if _, err := frontmostApp(); err != nil {
+		r.fail("could not query System Events, which on_focused hide and toggle-back need: %s", err)
+	} else {
+		r.ok("System Events can be queried")
+	}
+}
+
+// doctorTargets checks that the profiles, arrangements and color
+// presets the targets refer to exist.
+func (t *toggler) doctorTargets(r *doctorReport) {
+	names := []string{}
+	for name := range t.config.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		profiles, arrangements, presets []string
+		tmux                            bool
+	)
+	if list, err := t.app.ListProfiles("Name"); err != nil {
+		r.fail("could not list profiles: %s", err)
+	} else {
+		for _, p := range list {
+			profiles = append(profiles, p.Name())
+		}
+	}
+	if list, err := t.app.ListArrangements(); err != nil {
+		r.fail("could not list arrangements: %s", err)
+	} else {
+		arrangements = list
+	}
+	if list, err := t.app.ListColorPresets(); err != nil {
+		r.fail("could not list color presets: %s", err)
+	} else {
+		presets = list
+	}
+	if conns, err := t.app.TmuxConnections(); err != nil {
+		r.fail("could not list tmux connections: %s", err)
+	} else {
+		tmux = len(conns) > 0
+	}
+
+	for _, name := range names {
+		target := t.config.Targets[name]
+		if l := target.Launch; l != nil && l.Profile != "" && profiles != nil && !slices.Contains(profiles, l.Profile) {
+			r.fail("target %q launches with profile %q, which doesn't exist", name, l.Profile)
+		}
+		if m := target.Match; m.Profile != "" && profiles != nil && !slices.Contains(profiles, m.Profile) {
+			r.warn("target %q matches profile %q, which doesn't exist", name, m.Profile)
+		}
+		if a := target.Arrangement; a != "" && arrangements != nil && !slices.Contains(arrangements, a) {
+			r.fail("target %q restores arrangement %q, which doesn't exist", name, a)
+		}
+		if h := target.Highlight; h != nil && h.ColorPreset != "" && presets != nil && !slices.Contains(presets, h.ColorPreset) {
+			r.fail("target %q highlights with color preset %q, which doesn't exist", name, h.ColorPreset)
+		}
+		if target.Match.usesTmux() && !tmux {
+			r.warn("target %q matches tmux windows, but there is no tmux integration connection", name)
+		}
+	}
+	r.ok("checked %d targets", len(names))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// How long a flashed badge is shown by default.
+const defaultFlashDuration = time.Second
+
+// flash shows which session the toggle landed on, which helps when a
+// target has several identical panes.
+type flash struct {
+	// Badge is shown as the session's badge for a moment. It may
+	// refer to variables, such as "\(session.name)".
+	Badge string `json:"badge"`
+	// Duration is how long the badge is shown, such as "500ms". It
+	// defaults to a second.
+	Duration string `json:"duration"`
+	// Mark sets a mark on the cursor's line, which iTerm2 shows in the
+	// margin and which can be jumped to.
+	Mark bool `json:"mark"`
+
+	duration time.Duration
+}
+
+// compile validates the settings and prepares them for use.
+func (f *flash) compile() error {
+	if f.Badge == "" && !f.Mark {
+		return fmt.Errorf("flash needs a badge or a mark")
+	}
+	f.duration = defaultFlashDuration
+	if f.Duration != "" {
+		d, err := time.ParseDuration(f.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid flash duration: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("flash duration must be positive")
+		}
+		f.duration = d
+	}
+	return nil
+}
+
+// setBadgeFormat returns the escape sequence that sets the session's
+// badge.
+func setBadgeFormat(format string) []byte {
+	return fmt.Appendf(nil, "\x1b]1337;SetBadgeFormat=%s\a", base64.StdEncoding.EncodeToString([]byte(format)))
+}
+
+// setMark is the escape sequence that sets a mark on the cursor's line.
+var setMark = []byte("\x1b]1337;SetMark\a")
+
+// flashes tracks flashed sessions whose badges are yet to be
+// restored.
+type flashes struct {
+	mu      sync.Mutex
+	pending map[string]*badgeRestore
+}
+
+// badgeRestore holds the badge a session had before it was flashed.
+type badgeRestore struct {
+	timer *time.Timer
+	badge string
+}
+
+// flash applies f to the session. The badge is put back afterwards;
+// when the session is flashed again before that, to the badge it had
+// before the first flash.
+func (t *toggler) flash(s *iterm2.Session, f *flash) error {
+	if f.Mark {
+		err := s.Inject(setMark)
+		if err != nil {
+			return err
+		}
+	}
+	if f.Badge == "" {
+		return nil
+	}
+
+	t.flashes.mu.Lock()
+	defer t.flashes.mu.Unlock()
+
+	// a flashed badge is stored in the session's profile, so it can't
+	// be read back while a flash is pending
+	id := s.GetSessionID()
+	r := &badgeRestore{}
+	prev, pending := t.flashes.pending[id]
+	if pending {
+		r.badge = prev.badge
+	} else {
+		p, err := s.Profile("Badge Text")
+		if err != nil {
+			return err
+		}
+		r.badge = p.Property("Badge Text").String()
+	}
+
+	err := s.Inject(setBadgeFormat(f.Badge))
+	if err != nil {
+		return err
+	}
+	if pending {
+		prev.timer.Stop()
+	}
+
+	r.timer = time.AfterFunc(f.duration, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.flashes.mu.Lock()
+		if t.flashes.pending[id] != r {
+			// flashed again in the meantime
+			t.flashes.mu.Unlock()
+			return
+		}
+		delete(t.flashes.pending, id)
+		t.flashes.mu.Unlock()
+
+		err := s.Inject(setBadgeFormat(r.badge))
+		if err != nil {
+			log.Println("could not restore badge of session", id, err)
+		}
+	})
+	if t.flashes.pending == nil {
+		t.flashes.pending = map[string]*badgeRestore{}
+	}
+	t.flashes.pending[id] = r
+	return nil
+}
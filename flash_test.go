@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlashCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		flash   flash
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "badge", flash: flash{Badge: "vim"}, want: defaultFlashDuration},
+		{name: "mark", flash: flash{Mark: true}, want: defaultFlashDuration},
+		{name: "duration", flash: flash{Badge: "vim", Duration: "500ms"}, want: 500 * time.Millisecond},
+		{name: "nothing to flash", flash: flash{Duration: "1s"}, wantErr: true},
+		{name: "invalid duration", flash: flash{Badge: "vim", Duration: "soon"}, wantErr: true},
+		{name: "missing unit", flash: flash{Badge: "vim", Duration: "500"}, wantErr: true},
+		{name: "zero duration", flash: flash{Badge: "vim", Duration: "0s"}, wantErr: true},
+		{name: "negative duration", flash: flash{Badge: "vim", Duration: "-1s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.flash
+			err := f.compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compile() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && f.duration != tt.want {
+				t.Errorf("duration = %s, want %s", f.duration, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBadgeFormat(t *testing.T) {
+	format := "\\(session.name) ✓"
+	got := string(setBadgeFormat(format))
+	prefix, suffix := "\x1b]1337;SetBadgeFormat=", "\a"
+	if !strings.HasPrefix(got, prefix) || !strings.HasSuffix(got, suffix) {
+		t.Fatalf("setBadgeFormat() = %q, want an OSC 1337 SetBadgeFormat sequence", got)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(got, prefix), suffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != format {
+		t.Errorf("badge format = %q, want %q", b, format)
+	}
+}
@@ -3,14 +3,8 @@ module github.com/LeonB/iterm2-toggle-session
 go 1.24
 
 require (
+	github.com/andybrewer/mack v0.0.0-20200226161639-15be3d47cc54
+	github.com/gorilla/websocket v1.4.2
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
-	marwan.io/iterm2 v0.0.9
-)
-
-require (
-	github.com/andybrewer/mack v0.0.0-20200226161639-15be3d47cc54 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
-	github.com/muesli/cancelreader v0.2.2 // indirect
-	golang.org/x/sys v0.0.0-20220204135822-1c1b9b1eba6a // indirect
-	google.golang.org/protobuf v1.25.0 // indirect
+	google.golang.org/protobuf v1.25.0
 )
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// highlight changes the colors of a session when the toggle activates
+// it, to make it obvious where the toggle landed. Only the session's
+// copy of its profile is changed.
+type highlight struct {
+	// ColorPreset is the name of a color preset to apply.
+	ColorPreset string `json:"color_preset"`
+	// TabColor is a tab color to use, such as "#ff8800".
+	TabColor string `json:"tab_color"`
+	// RestoreAfter, when set, restores the previous colors after this
+	// long, such as "2s".
+	RestoreAfter string `json:"restore_after"`
+
+	tabColor     iterm2.Color
+	restoreAfter time.Duration
+}
+
+// compile validates the settings and prepares them for use.
+func (h *highlight) compile() error {
+	if h.ColorPreset == "" && h.TabColor == "" {
+		return fmt.Errorf("highlight needs a color_preset or a tab_color")
+	}
+	if h.TabColor != "" {
+		c, err := parseColor(h.TabColor)
+		if err != nil {
+			return err
+		}
+		h.tabColor = c
+	}
+	if h.RestoreAfter != "" {
+		d, err := time.ParseDuration(h.RestoreAfter)
+		if err != nil {
+			return fmt.Errorf("invalid restore_after: %w", err)
+		}
+		h.restoreAfter = d
+	}
+	return nil
+}
+
+// parseColor parses a color written as "#rrggbb".
+func parseColor(s string) (iterm2.Color, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || len(hex) != 6 {
+		return iterm2.Color{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return iterm2.Color{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+	return iterm2.Color{
+		Red:        float64(n>>16&0xff) / 255,
+		Green:      float64(n>>8&0xff) / 255,
+		Blue:       float64(n&0xff) / 255,
+		Alpha:      1,
+		ColorSpace: "sRGB",
+	}, nil
+}
+
+// highlights tracks highlighted sessions whose colors are yet to be
+// restored.
+type highlights struct {
+	mu      sync.Mutex
+	pending map[string]*restore
+}
+
+// restore holds the colors a session had before it was highlighted.
+type restore struct {
+	timer *time.Timer
+	saved map[string]any
+	// known holds the keys whose colors were read, including unset
+	// ones that aren't in saved.
+	known map[string]bool
+}
+
+// highlight applies h to the session.
+func (t *toggler) highlight(s *iterm2.Session, h *highlight) error {
+	props := map[string]any{}
+	if h.ColorPreset != "" {
+		preset, err := t.app.ColorPreset(h.ColorPreset)
+		if err != nil {
+			return err
+		}
+		maps.Copy(props, preset.Properties())
+	}
+	if h.TabColor != "" {
+		props["Use Tab Color"] = true
+		props["Tab Color"] = h.tabColor
+	}
+
+	if h.restoreAfter == 0 {
+		return s.SetProfileProperties(props)
+	}
+
+	t.highlights.mu.Lock()
+	defer t.highlights.mu.Unlock()
+
+	// when the session is still highlighted, keep the colors it had
+	// before that, and add the ones of keys only this highlight sets
+	id := s.GetSessionID()
+	r := &restore{saved: map[string]any{}, known: map[string]bool{}}
+	prev, pending := t.highlights.pending[id]
+	if pending {
+		maps.Copy(r.saved, prev.saved)
+		maps.Copy(r.known, prev.known)
+	}
+	keys := []string{}
+	for key := range props {
+		if !r.known[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		p, err := s.Profile(keys...)
+		if err != nil {
+			return err
+		}
+		// iTerm2 rejects null, so unset keys aren't restored, except
+		// for turning the tab color off again, which is the default
+		for _, key := range keys {
+			r.known[key] = true
+			v := p.Property(key)
+			switch {
+			case !v.IsNull():
+				r.saved[key] = v
+			case key == "Use Tab Color":
+				r.saved[key] = false
+			}
+		}
+	}
+	if pending {
+		prev.timer.Stop()
+	}
+
+	// the restore is scheduled even if highlighting fails half way
+	err := s.SetProfileProperties(props)
+
+	r.timer = time.AfterFunc(h.restoreAfter, func() {
+		// lock in the same order as toggles do, so a toggle can't
+		// highlight the session between the check and the restore
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.highlights.mu.Lock()
+		if t.highlights.pending[id] != r {
+			// highlighted again in the meantime
+			t.highlights.mu.Unlock()
+			return
+		}
+		delete(t.highlights.pending, id)
+		t.highlights.mu.Unlock()
+
+		err := s.SetProfileProperties(r.saved)
+		if err != nil {
+			log.Println("could not restore colors of session", id, err)
+		}
+	})
+	if t.highlights.pending == nil {
+		t.highlights.pending = map[string]*restore{}
+	}
+	t.highlights.pending[id] = r
+	return err
+}
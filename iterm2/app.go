@@ -2,9 +2,10 @@ package iterm2
 
 import (
 	"fmt"
+	"sync"
 
-	"marwan.io/iterm2/api"
-	"marwan.io/iterm2/client"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
 )
 
 // NewApp establishes a connection
@@ -25,12 +26,19 @@ func NewApp(name string) (*App, error) {
 
 type App struct {
 	c *client.Client
+
+	// txMu is held during a Transaction.
+	txMu sync.Mutex
 }
 
 func (a *App) CreateWindow() (*Window, error) {
+	return a.CreateWindowWithOptions(CreateTabOptions{})
+}
+
+func (a *App) CreateWindowWithOptions(opts CreateTabOptions) (*Window, error) {
 	resp, err := a.c.Call(&api.ClientOriginatedMessage{
 		Submessage: &api.ClientOriginatedMessage_CreateTabRequest{
-			CreateTabRequest: &api.CreateTabRequest{},
+			CreateTabRequest: opts.createTabRequest(),
 		},
 	})
 	if err != nil {
@@ -69,7 +77,9 @@ func (a *App) ListWindows() ([]*Window, error) {
 func (a *App) Focus() ([]FocusChangedNotification, error) {
 	list := []FocusChangedNotification{}
 	resp, err := a.c.Call(&api.ClientOriginatedMessage{
-		Submessage: &api.ClientOriginatedMessage_FocusRequest{},
+		Submessage: &api.ClientOriginatedMessage_FocusRequest{
+			FocusRequest: &api.FocusRequest{},
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("could not focus: %w", err)
@@ -108,16 +118,30 @@ func (a *App) SelectMenuItem(item string) error {
 	return nil
 }
 
-func (a App) Activate(raiseAllWindows bool, ignoreOtherApps bool) error {
-	orderWindowFront := true
-	_, err := a.c.Call(&api.ClientOriginatedMessage{
-		Submessage: &api.ClientOriginatedMessage_ActivateRequest{ActivateRequest: &api.ActivateRequest{
-			OrderWindowFront: &orderWindowFront,
-			ActivateApp: &api.ActivateRequest_App{
-				RaiseAllWindows:   &raiseAllWindows,
-				IgnoringOtherApps: &ignoreOtherApps,
-			},
-		}},
+func (a *App) Activate(raiseAllWindows bool, ignoreOtherApps bool) error {
+	return a.ActivateWithOptions(ActivateOptions{
+		OrderWindowFront:  true,
+		ActivateApp:       true,
+		RaiseAllWindows:   raiseAllWindows,
+		IgnoringOtherApps: ignoreOtherApps,
 	})
-	return err
+}
+
+func (a *App) ActivateWithOptions(opts ActivateOptions) error {
+	req := activateRequest(opts)
+	// select_tab is only valid for tab and session identifiers
+	req.SelectTab = nil
+
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ActivateRequest{
+			ActivateRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error activating app: %w", err)
+	}
+	if status := resp.GetActivateResponse().GetStatus(); status != api.ActivateResponse_OK {
+		return fmt.Errorf("unexpected status for activate request: %s", status)
+	}
+	return nil
 }
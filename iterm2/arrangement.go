@@ -0,0 +1,66 @@
+package iterm2
+
+import (
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// SaveArrangement saves all windows as an arrangement with the given
+// name.
+func (a *App) SaveArrangement(name string) error {
+	_, err := savedArrangement(a.c, api.SavedArrangementRequest_SAVE, name, "")
+	return err
+}
+
+// RestoreArrangement restores the arrangement with the given name in
+// new windows.
+func (a *App) RestoreArrangement(name string) error {
+	_, err := savedArrangement(a.c, api.SavedArrangementRequest_RESTORE, name, "")
+	return err
+}
+
+// ListArrangements returns the names of all saved arrangements.
+func (a *App) ListArrangements() ([]string, error) {
+	return savedArrangement(a.c, api.SavedArrangementRequest_LIST, "", "")
+}
+
+// SaveArrangement saves the tabs of the window as an arrangement with
+// the given name.
+func (w *Window) SaveArrangement(name string) error {
+	_, err := savedArrangement(w.c, api.SavedArrangementRequest_SAVE, name, w.id)
+	return err
+}
+
+// RestoreArrangement restores the arrangement with the given name as
+// tabs in the window.
+func (w *Window) RestoreArrangement(name string) error {
+	_, err := savedArrangement(w.c, api.SavedArrangementRequest_RESTORE, name, w.id)
+	return err
+}
+
+func savedArrangement(c *client.Client, action api.SavedArrangementRequest_Action, name, windowID string) ([]string, error) {
+	req := &api.SavedArrangementRequest{
+		Action: action.Enum(),
+	}
+	if name != "" {
+		req.Name = &name
+	}
+	if windowID != "" {
+		req.WindowId = &windowID
+	}
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_SavedArrangementRequest{
+			SavedArrangementRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not %s arrangement %q: %w", action, name, err)
+	}
+	sar := resp.GetSavedArrangementResponse()
+	if status := sar.GetStatus(); status != api.SavedArrangementResponse_OK {
+		return nil, fmt.Errorf("unexpected status for %s arrangement %q: %s", action, name, status)
+	}
+	return sar.GetNames(), nil
+}
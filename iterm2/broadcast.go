@@ -0,0 +1,95 @@
+package iterm2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// BroadcastDomain is a set of sessions that all receive the keyboard
+// input sent to any of them. The sessions of a domain must be in the
+// same window, and a session can be in one domain only.
+type BroadcastDomain []*Session
+
+// BroadcastDomains returns the current broadcast domains.
+func (a *App) BroadcastDomains() ([]BroadcastDomain, error) {
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_GetBroadcastDomainsRequest{
+			GetBroadcastDomainsRequest: &api.GetBroadcastDomainsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get broadcast domains: %w", err)
+	}
+	return broadcastDomains(a.c, resp.GetGetBroadcastDomainsResponse().GetBroadcastDomains()), nil
+}
+
+// SetBroadcastDomains replaces all broadcast domains. Passing no
+// domains turns off broadcasting.
+func (a *App) SetBroadcastDomains(domains []BroadcastDomain) error {
+	list := []*api.BroadcastDomain{}
+	for _, d := range domains {
+		ids := []string{}
+		for _, s := range d {
+			ids = append(ids, s.id)
+		}
+		list = append(list, &api.BroadcastDomain{SessionIds: ids})
+	}
+
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_SetBroadcastDomainsRequest{
+			SetBroadcastDomainsRequest: &api.SetBroadcastDomainsRequest{
+				BroadcastDomains: list,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not set broadcast domains: %w", err)
+	}
+	if status := resp.GetSetBroadcastDomainsResponse().GetStatus(); status != api.SetBroadcastDomainsResponse_OK {
+		return fmt.Errorf("unexpected status for set broadcast domains request: %s", status)
+	}
+	return nil
+}
+
+// WatchBroadcastDomains streams all broadcast domains each time they
+// change, until ctx is done.
+func (a *App) WatchBroadcastDomains(ctx context.Context) (<-chan []BroadcastDomain, error) {
+	notifications, err := subscribe(ctx, a.c, &api.NotificationRequest{
+		NotificationType: api.NotificationType_NOTIFY_ON_BROADCAST_CHANGE.Enum(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []BroadcastDomain)
+	go func() {
+		defer close(ch)
+		for n := range notifications {
+			bdc := n.GetBroadcastDomainsChanged()
+			if bdc == nil {
+				continue
+			}
+			select {
+			case ch <- broadcastDomains(a.c, bdc.GetBroadcastDomains()):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func broadcastDomains(c *client.Client, list []*api.BroadcastDomain) []BroadcastDomain {
+	domains := []BroadcastDomain{}
+	for _, bd := range list {
+		d := BroadcastDomain{}
+		for _, id := range bd.GetSessionIds() {
+			d = append(d, &Session{c: c, id: id})
+		}
+		domains = append(domains, d)
+	}
+	return domains
+}
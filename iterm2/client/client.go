@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -13,10 +14,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
 	"github.com/andybrewer/mack"
 	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
-	"marwan.io/iterm2/api"
 )
 
 // New returns a new websocket connection that talks to the iTerm2
@@ -86,6 +87,7 @@ func newClient(appName, cookie string) (*Client, error) {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	cl.cancel = cancel
+	cl.done = ctx.Done()
 	go cl.readWorker(ctx)
 	go cl.writeWorker()
 	return cl, nil
@@ -98,18 +100,28 @@ type Client struct {
 	rpcs    map[int64]chan<- *api.ServerOriginatedMessage
 	mu      sync.Mutex
 	cancel  context.CancelFunc
+	done    <-chan struct{}
 	writeCh chan writeReq
+	subs    map[*subscriber]struct{}
 }
 
+// ErrClosed is returned by Call when the client has been closed.
+var ErrClosed = errors.New("client is closed")
+
 type writeReq struct {
 	msg  []byte
 	resp chan error
 }
 
 func (c *Client) writeWorker() {
-	for req := range c.writeCh {
-		err := c.c.WriteMessage(websocket.BinaryMessage, req.msg)
-		req.resp <- err
+	for {
+		select {
+		case <-c.done:
+			return
+		case req := <-c.writeCh:
+			err := c.c.WriteMessage(websocket.BinaryMessage, req.msg)
+			req.resp <- err
+		}
 	}
 }
 
@@ -129,6 +141,10 @@ func (c *Client) readWorker(ctx context.Context) {
 			fmt.Fprintln(os.Stderr, err)
 			continue
 		}
+		if n := resp.GetNotification(); n != nil {
+			c.notify(n)
+			continue
+		}
 		c.mu.Lock()
 		ch, ok := c.rpcs[resp.GetId()]
 		delete(c.rpcs, resp.GetId())
@@ -148,17 +164,31 @@ func (c *Client) Call(req *api.ClientOriginatedMessage) (*api.ServerOriginatedMe
 	c.mu.Lock()
 	c.rpcs[req.GetId()] = ch
 	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.rpcs, req.GetId())
+		c.mu.Unlock()
+	}()
 	msg, err := proto.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 	wr := writeReq{msg: msg, resp: make(chan error, 1)}
-	c.writeCh <- wr
+	select {
+	case c.writeCh <- wr:
+	case <-c.done:
+		return nil, ErrClosed
+	}
 	err = <-wr.resp
 	if err != nil {
 		return nil, fmt.Errorf("error writing to websocket: %w", err)
 	}
-	resp := <-ch
+	var resp *api.ServerOriginatedMessage
+	select {
+	case resp = <-ch:
+	case <-c.done:
+		return nil, ErrClosed
+	}
 	if resp.GetError() != "" {
 		return nil, fmt.Errorf("error from server: %v", resp.GetError())
 	}
@@ -168,8 +198,7 @@ func (c *Client) Call(req *api.ClientOriginatedMessage) (*api.ServerOriginatedMe
 // Close closes the websocket connection
 // and frees any goroutine resources
 func (c *Client) Close() error {
-	// TODO: if a *Client.Call is in flight, this will cause it to panic
-	close(c.writeCh)
+	// calls in flight return ErrClosed
 	c.cancel()
 	return c.c.Close()
 }
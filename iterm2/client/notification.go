@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// Subscribe returns a channel that receives every notification iTerm2
+// sends on this connection, until ctx is done. Notifications are
+// queued per subscriber, so a slow reader never blocks the connection
+// and may safely call Call while handling a notification.
+//
+// Subscribe only taps the stream of notifications; asking iTerm2 to
+// send them is done with a NotificationRequest.
+func (c *Client) Subscribe(ctx context.Context) <-chan *api.Notification {
+	s := &subscriber{
+		ready: make(chan struct{}, 1),
+		out:   make(chan *api.Notification),
+	}
+
+	c.mu.Lock()
+	if c.subs == nil {
+		c.subs = make(map[*subscriber]struct{})
+	}
+	c.subs[s] = struct{}{}
+	c.mu.Unlock()
+
+	go func() {
+		defer close(s.out)
+		defer func() {
+			c.mu.Lock()
+			delete(c.subs, s)
+			c.mu.Unlock()
+		}()
+		for {
+			n, ok := s.next()
+			if !ok {
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.ready:
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case s.out <- n:
+			}
+		}
+	}()
+
+	return s.out
+}
+
+// notify hands a notification to all subscribers.
+func (c *Client) notify(n *api.Notification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for s := range c.subs {
+		s.push(n)
+	}
+}
+
+// subscriber is an unbounded queue of notifications for one Subscribe
+// call.
+type subscriber struct {
+	mu    sync.Mutex
+	queue []*api.Notification
+	ready chan struct{}
+	out   chan *api.Notification
+}
+
+func (s *subscriber) push(n *api.Notification) {
+	s.mu.Lock()
+	s.queue = append(s.queue, n)
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) next() (*api.Notification, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+	n := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	return n, true
+}
@@ -0,0 +1,85 @@
+package iterm2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// Close closes the session. Unless force is set, the user may be asked
+// to confirm, for example when a program is still running.
+func (s *Session) Close(force bool) error {
+	return closeRequest(s.c, &api.CloseRequest{
+		Target: &api.CloseRequest_Sessions{
+			Sessions: &api.CloseRequest_CloseSessions{SessionIds: []string{s.id}},
+		},
+		Force: &force,
+	}, "session", s.id)
+}
+
+// Close closes the tab and all its sessions, see Session.Close.
+func (t *Tab) Close(force bool) error {
+	return closeRequest(t.c, &api.CloseRequest{
+		Target: &api.CloseRequest_Tabs{
+			Tabs: &api.CloseRequest_CloseTabs{TabIds: []string{t.id}},
+		},
+		Force: &force,
+	}, "tab", t.id)
+}
+
+// Close closes the window and all its tabs, see Session.Close.
+func (w *Window) Close(force bool) error {
+	return closeRequest(w.c, &api.CloseRequest{
+		Target: &api.CloseRequest_Windows{
+			Windows: &api.CloseRequest_CloseWindows{WindowIds: []string{w.id}},
+		},
+		Force: &force,
+	}, "window", w.id)
+}
+
+func closeRequest(c *client.Client, req *api.CloseRequest, kind, id string) error {
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_CloseRequest{
+			CloseRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error closing %s %q: %w", kind, id, err)
+	}
+	for _, status := range resp.GetCloseResponse().GetStatuses() {
+		if status != api.CloseResponse_OK {
+			return fmt.Errorf("unexpected status for closing %s %q: %s", kind, id, status)
+		}
+	}
+	return nil
+}
+
+// WatchTerminations streams the IDs of sessions as they end, however
+// they were closed, until ctx is done.
+func (a *App) WatchTerminations(ctx context.Context) (<-chan string, error) {
+	notifications, err := subscribe(ctx, a.c, &api.NotificationRequest{
+		NotificationType: api.NotificationType_NOTIFY_ON_TERMINATE_SESSION.Enum(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for n := range notifications {
+			tsn := n.GetTerminateSessionNotification()
+			if tsn == nil {
+				continue
+			}
+			select {
+			case ch <- tsn.GetSessionId():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
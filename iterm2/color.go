@@ -0,0 +1,89 @@
+package iterm2
+
+import (
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// Color is a color as stored in profile properties such as
+// "Background Color" or "Tab Color". Components range from 0 to 1.
+type Color struct {
+	Red        float64 `json:"Red Component"`
+	Green      float64 `json:"Green Component"`
+	Blue       float64 `json:"Blue Component"`
+	Alpha      float64 `json:"Alpha Component"`
+	ColorSpace string  `json:"Color Space,omitempty"`
+}
+
+// ColorPreset is a named set of profile colors, as listed in the
+// Color Presets menu of the profile preferences.
+type ColorPreset struct {
+	Name string
+	// Colors holds the colors by profile property key, such as
+	// "Foreground Color".
+	Colors map[string]Color
+}
+
+// ListColorPresets returns the names of all color presets.
+func (a *App) ListColorPresets() ([]string, error) {
+	resp, err := colorPresetRequest(a.c, &api.ColorPresetRequest{
+		Request: &api.ColorPresetRequest_ListPresets_{
+			ListPresets: &api.ColorPresetRequest_ListPresets{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetListPresets().GetName(), nil
+}
+
+// ColorPreset returns the color preset with the given name.
+func (a *App) ColorPreset(name string) (*ColorPreset, error) {
+	resp, err := colorPresetRequest(a.c, &api.ColorPresetRequest{
+		Request: &api.ColorPresetRequest_GetPreset_{
+			GetPreset: &api.ColorPresetRequest_GetPreset{Name: &name},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting color preset %q: %w", name, err)
+	}
+	preset := &ColorPreset{Name: name, Colors: map[string]Color{}}
+	for _, cs := range resp.GetGetPreset().GetColorSettings() {
+		preset.Colors[cs.GetKey()] = Color{
+			Red:        float64(cs.GetRed()),
+			Green:      float64(cs.GetGreen()),
+			Blue:       float64(cs.GetBlue()),
+			Alpha:      float64(cs.GetAlpha()),
+			ColorSpace: cs.GetColorSpace(),
+		}
+	}
+	return preset, nil
+}
+
+// Properties returns the preset's colors as profile properties, for
+// Profile.SetProperties.
+func (p *ColorPreset) Properties() map[string]any {
+	props := make(map[string]any, len(p.Colors))
+	for key, c := range p.Colors {
+		props[key] = c
+	}
+	return props
+}
+
+func colorPresetRequest(c *client.Client, req *api.ColorPresetRequest) (*api.ColorPresetResponse, error) {
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ColorPresetRequest{
+			ColorPresetRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not send color preset request: %w", err)
+	}
+	cpr := resp.GetColorPresetResponse()
+	if status := cpr.GetStatus(); status != api.ColorPresetResponse_OK {
+		return nil, fmt.Errorf("unexpected status for color preset request: %s", status)
+	}
+	return cpr, nil
+}
@@ -0,0 +1,54 @@
+package iterm2
+
+import (
+	"context"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// CustomEscapeSequence is sent by a program in a session that writes
+//
+//	OSC 1337 ; Custom=id=<identity>:<payload> ST
+//
+// iTerm2 does nothing with it, other than passing it on to scripts.
+type CustomEscapeSequence struct {
+	// Session is the session the sequence was written to.
+	Session *Session
+	// Identity tells which script the sequence is meant for.
+	Identity string
+	Payload  string
+}
+
+// WatchCustomEscapeSequences streams the custom escape sequences of
+// all sessions until ctx is done.
+func (a *App) WatchCustomEscapeSequences(ctx context.Context) (<-chan CustomEscapeSequence, error) {
+	session := "all"
+	notifications, err := subscribe(ctx, a.c, &api.NotificationRequest{
+		Session:          &session,
+		NotificationType: api.NotificationType_NOTIFY_ON_CUSTOM_ESCAPE_SEQUENCE.Enum(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan CustomEscapeSequence)
+	go func() {
+		defer close(ch)
+		for n := range notifications {
+			cesn := n.GetCustomEscapeSequenceNotification()
+			if cesn == nil {
+				continue
+			}
+			select {
+			case ch <- CustomEscapeSequence{
+				Session:  &Session{c: a.c, id: cesn.GetSession()},
+				Identity: cesn.GetSenderIdentity(),
+				Payload:  cesn.GetPayload(),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,153 @@
+package iterm2
+
+import (
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// Focus describes what currently has keyboard focus in iTerm2.
+//
+// Window, Tab and Session are resolved top-down: Tab is only set
+// when Window is set, and Session only when Tab is set. Any of them
+// can be nil, for example when iTerm2 has no terminal windows open.
+type Focus struct {
+	// AppActive is true when iTerm2 is the frontmost application.
+	AppActive bool
+	// Window is the key terminal window. When a non-terminal window
+	// (such as the preferences panel) is key, it is the current
+	// terminal window instead.
+	Window *Window
+	// Tab is the selected tab of Window.
+	Tab *Tab
+	// Session is the active session of Tab.
+	Session *Session
+}
+
+// CurrentFocus returns the key window, its selected tab, the active
+// session in that tab and whether iTerm2 is the active application.
+func (a *App) CurrentFocus() (*Focus, error) {
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_FocusRequest{
+			FocusRequest: &api.FocusRequest{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get focus: %w", err)
+	}
+	notifications := resp.GetFocusResponse().GetNotifications()
+
+	resp, err = a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ListSessionsRequest{
+			ListSessionsRequest: &api.ListSessionsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list sessions: %w", err)
+	}
+
+	ids := resolveFocus(notifications, resp.GetListSessionsResponse())
+	f := &Focus{AppActive: ids.appActive}
+	if ids.window == "" {
+		return f, nil
+	}
+	f.Window = &Window{c: a.c, id: ids.window}
+	if ids.tab == "" {
+		return f, nil
+	}
+	f.Tab = &Tab{c: a.c, id: ids.tab, windowID: ids.window}
+	if ids.session == "" {
+		return f, nil
+	}
+	f.Session = &Session{c: a.c, id: ids.session}
+	return f, nil
+}
+
+// focusIDs holds the identifiers resolved by resolveFocus.
+type focusIDs struct {
+	appActive bool
+	window    string
+	tab       string
+	session   string
+}
+
+// resolveFocus combines the notifications of a FocusResponse with
+// the current layout. The FocusResponse contains one notification
+// per window, one selected tab per window and one active session
+// per tab, so the layout is needed to tell which of those belong to
+// the key window. Identifiers that are not part of the layout are
+// ignored, because the two responses are not fetched atomically.
+func resolveFocus(notifications []*api.FocusChangedNotification, layout *api.ListSessionsResponse) focusIDs {
+	var (
+		ids           focusIDs
+		keyWindow     string
+		currentWindow string
+		selectedTabs  = map[string]bool{}
+		activeSession = map[string]bool{}
+	)
+	for _, n := range notifications {
+		switch e := n.GetEvent().(type) {
+		case *api.FocusChangedNotification_ApplicationActive:
+			ids.appActive = n.GetApplicationActive()
+		case *api.FocusChangedNotification_Window_:
+			switch e.Window.GetWindowStatus() {
+			case api.FocusChangedNotification_Window_TERMINAL_WINDOW_BECAME_KEY:
+				keyWindow = e.Window.GetWindowId()
+			case api.FocusChangedNotification_Window_TERMINAL_WINDOW_IS_CURRENT:
+				currentWindow = e.Window.GetWindowId()
+			case api.FocusChangedNotification_Window_TERMINAL_WINDOW_RESIGNED_KEY:
+				if keyWindow == e.Window.GetWindowId() {
+					keyWindow = ""
+				}
+			}
+		case *api.FocusChangedNotification_SelectedTab:
+			selectedTabs[e.SelectedTab] = true
+		case *api.FocusChangedNotification_Session:
+			activeSession[e.Session] = true
+		}
+	}
+
+	windowID := keyWindow
+	if windowID == "" {
+		windowID = currentWindow
+	}
+	if windowID == "" {
+		return ids
+	}
+
+	for _, w := range layout.GetWindows() {
+		if w.GetWindowId() != windowID {
+			continue
+		}
+		ids.window = windowID
+		for _, t := range w.GetTabs() {
+			if !selectedTabs[t.GetTabId()] {
+				continue
+			}
+			ids.tab = t.GetTabId()
+			for _, id := range sessionIDs(t.GetRoot()) {
+				if activeSession[id] {
+					ids.session = id
+					break
+				}
+			}
+			break
+		}
+		break
+	}
+	return ids
+}
+
+// sessionIDs returns the identifiers of all sessions in a split
+// tree, in layout order.
+func sessionIDs(node *api.SplitTreeNode) []string {
+	list := []string{}
+	for _, link := range node.GetLinks() {
+		if s := link.GetSession(); s != nil {
+			list = append(list, s.GetUniqueIdentifier())
+			continue
+		}
+		list = append(list, sessionIDs(link.GetNode())...)
+	}
+	return list
+}
@@ -1,8 +1,10 @@
 package iterm2
 
 import (
-	"marwan.io/iterm2/api"
-	"marwan.io/iterm2/client"
+	"context"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
 )
 
 type FocusChangedNotification struct {
@@ -15,5 +17,34 @@ func (n *FocusChangedNotification) GetWindow() *Window {
 	if w == nil {
 		return nil
 	}
-    return &Window{c: n.c, id: *n.FocusChangedNotification.GetWindow().WindowId}
+	return &Window{c: n.c, id: w.GetWindowId()}
+}
+
+// WatchFocus streams focus changes until ctx is done: iTerm2 becoming
+// active or inactive, the key window changing, or the selected tab or
+// active session changing.
+func (a *App) WatchFocus(ctx context.Context) (<-chan FocusChangedNotification, error) {
+	notifications, err := subscribe(ctx, a.c, &api.NotificationRequest{
+		NotificationType: api.NotificationType_NOTIFY_ON_FOCUS_CHANGE.Enum(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan FocusChangedNotification)
+	go func() {
+		defer close(ch)
+		for n := range notifications {
+			fcn := n.GetFocusChangedNotification()
+			if fcn == nil {
+				continue
+			}
+			select {
+			case ch <- FocusChangedNotification{c: a.c, FocusChangedNotification: fcn}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
 }
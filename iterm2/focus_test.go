@@ -0,0 +1,255 @@
+package iterm2
+
+import (
+	"testing"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"google.golang.org/protobuf/proto"
+)
+
+func appActive(active bool) *api.FocusChangedNotification {
+	return &api.FocusChangedNotification{
+		Event: &api.FocusChangedNotification_ApplicationActive{ApplicationActive: active},
+	}
+}
+
+func windowStatus(id string, status api.FocusChangedNotification_Window_WindowStatus) *api.FocusChangedNotification {
+	return &api.FocusChangedNotification{
+		Event: &api.FocusChangedNotification_Window_{
+			Window: &api.FocusChangedNotification_Window{
+				WindowStatus: status.Enum(),
+				WindowId:     proto.String(id),
+			},
+		},
+	}
+}
+
+func selectedTab(id string) *api.FocusChangedNotification {
+	return &api.FocusChangedNotification{
+		Event: &api.FocusChangedNotification_SelectedTab{SelectedTab: id},
+	}
+}
+
+func activeSession(id string) *api.FocusChangedNotification {
+	return &api.FocusChangedNotification{
+		Event: &api.FocusChangedNotification_Session{Session: id},
+	}
+}
+
+func sessionLink(id string) *api.SplitTreeNode_SplitTreeLink {
+	return &api.SplitTreeNode_SplitTreeLink{
+		Child: &api.SplitTreeNode_SplitTreeLink_Session{
+			Session: &api.SessionSummary{UniqueIdentifier: proto.String(id)},
+		},
+	}
+}
+
+func nodeLink(links ...*api.SplitTreeNode_SplitTreeLink) *api.SplitTreeNode_SplitTreeLink {
+	return &api.SplitTreeNode_SplitTreeLink{
+		Child: &api.SplitTreeNode_SplitTreeLink_Node{
+			Node: &api.SplitTreeNode{Links: links},
+		},
+	}
+}
+
+func layoutTab(id string, links ...*api.SplitTreeNode_SplitTreeLink) *api.ListSessionsResponse_Tab {
+	return &api.ListSessionsResponse_Tab{
+		TabId: proto.String(id),
+		Root:  &api.SplitTreeNode{Links: links},
+	}
+}
+
+func layoutWindow(id string, tabs ...*api.ListSessionsResponse_Tab) *api.ListSessionsResponse_Window {
+	return &api.ListSessionsResponse_Window{
+		WindowId: proto.String(id),
+		Tabs:     tabs,
+	}
+}
+
+func TestResolveFocus(t *testing.T) {
+	const (
+		becameKey   = api.FocusChangedNotification_Window_TERMINAL_WINDOW_BECAME_KEY
+		isCurrent   = api.FocusChangedNotification_Window_TERMINAL_WINDOW_IS_CURRENT
+		resignedKey = api.FocusChangedNotification_Window_TERMINAL_WINDOW_RESIGNED_KEY
+	)
+
+	// two windows with two tabs each; tab 4 has nested splits
+	layout := &api.ListSessionsResponse{
+		Windows: []*api.ListSessionsResponse_Window{
+			layoutWindow("w1",
+				layoutTab("1", sessionLink("s1")),
+				layoutTab("2", sessionLink("s2a"), sessionLink("s2b")),
+			),
+			layoutWindow("w2",
+				layoutTab("3", sessionLink("s3")),
+				layoutTab("4",
+					sessionLink("s4a"),
+					nodeLink(sessionLink("s4b"), nodeLink(sessionLink("s4c"), sessionLink("s4d"))),
+				),
+			),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		notifications []*api.FocusChangedNotification
+		want          focusIDs
+	}{
+		{
+			name:          "no notifications",
+			notifications: nil,
+			want:          focusIDs{},
+		},
+		{
+			name: "no window notification",
+			notifications: []*api.FocusChangedNotification{
+				appActive(true),
+				selectedTab("1"),
+				activeSession("s1"),
+			},
+			want: focusIDs{appActive: true},
+		},
+		{
+			name: "key window",
+			notifications: []*api.FocusChangedNotification{
+				appActive(true),
+				windowStatus("w1", becameKey),
+				selectedTab("2"),
+				selectedTab("3"),
+				activeSession("s2b"),
+				activeSession("s3"),
+			},
+			want: focusIDs{appActive: true, window: "w1", tab: "2", session: "s2b"},
+		},
+		{
+			name: "key window resigns after becoming key",
+			notifications: []*api.FocusChangedNotification{
+				appActive(true),
+				windowStatus("w1", becameKey),
+				windowStatus("w1", resignedKey),
+				selectedTab("1"),
+				activeSession("s1"),
+			},
+			want: focusIDs{appActive: true},
+		},
+		{
+			name: "another window resigning keeps the key window",
+			notifications: []*api.FocusChangedNotification{
+				windowStatus("w1", becameKey),
+				windowStatus("w2", resignedKey),
+				selectedTab("1"),
+				activeSession("s1"),
+			},
+			want: focusIDs{window: "w1", tab: "1", session: "s1"},
+		},
+		{
+			name: "becamekey wins over is current",
+			notifications: []*api.FocusChangedNotification{
+				windowStatus("w2", isCurrent),
+				windowStatus("w1", becameKey),
+				selectedTab("1"),
+				selectedTab("3"),
+				activeSession("s1"),
+				activeSession("s3"),
+			},
+			want: focusIDs{window: "w1", tab: "1", session: "s1"},
+		},
+		{
+			name: "falls back to is current",
+			notifications: []*api.FocusChangedNotification{
+				appActive(true),
+				windowStatus("w1", becameKey),
+				windowStatus("w1", resignedKey),
+				windowStatus("w2", isCurrent),
+				selectedTab("1"),
+				selectedTab("3"),
+				activeSession("s1"),
+				activeSession("s3"),
+			},
+			want: focusIDs{appActive: true, window: "w2", tab: "3", session: "s3"},
+		},
+		{
+			name: "window not in layout",
+			notifications: []*api.FocusChangedNotification{
+				windowStatus("w9", becameKey),
+				selectedTab("1"),
+				activeSession("s1"),
+			},
+			want: focusIDs{},
+		},
+		{
+			name: "tab not in layout",
+			notifications: []*api.FocusChangedNotification{
+				windowStatus("w1", becameKey),
+				selectedTab("9"),
+				activeSession("s1"),
+			},
+			want: focusIDs{window: "w1"},
+		},
+		{
+			name: "session not in layout",
+			notifications: []*api.FocusChangedNotification{
+				windowStatus("w1", becameKey),
+				selectedTab("1"),
+				activeSession("s9"),
+			},
+			want: focusIDs{window: "w1", tab: "1"},
+		},
+		{
+			name: "active session nested in split tree",
+			notifications: []*api.FocusChangedNotification{
+				appActive(true),
+				windowStatus("w2", becameKey),
+				selectedTab("4"),
+				activeSession("s4c"),
+			},
+			want: focusIDs{appActive: true, window: "w2", tab: "4", session: "s4c"},
+		},
+		{
+			name: "app not active",
+			notifications: []*api.FocusChangedNotification{
+				appActive(false),
+				windowStatus("w1", becameKey),
+				selectedTab("1"),
+				activeSession("s1"),
+			},
+			want: focusIDs{window: "w1", tab: "1", session: "s1"},
+		},
+		{
+			name: "app resigns after becoming active",
+			notifications: []*api.FocusChangedNotification{
+				appActive(true),
+				appActive(false),
+				windowStatus("w1", becameKey),
+			},
+			want: focusIDs{window: "w1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveFocus(tt.notifications, layout)
+			if got != tt.want {
+				t.Errorf("resolveFocus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionIDs(t *testing.T) {
+	root := &api.SplitTreeNode{Links: []*api.SplitTreeNode_SplitTreeLink{
+		sessionLink("a"),
+		nodeLink(sessionLink("b"), nodeLink(sessionLink("c"), sessionLink("d"))),
+		sessionLink("e"),
+	}}
+	got := sessionIDs(root)
+	want := []string{"a", "b", "c", "d", "e"}
+	if len(got) != len(want) {
+		t.Fatalf("sessionIDs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sessionIDs() = %v, want %v", got, want)
+		}
+	}
+}
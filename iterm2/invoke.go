@@ -0,0 +1,189 @@
+package iterm2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// invocation returns the call of the function fn with args, such as
+// iterm2.set_title(title: "vim"). Arguments are written as literals,
+// so quotes and backslashes in them can't change the call.
+func invocation(fn string, args map[string]any) (string, error) {
+	for _, part := range strings.Split(fn, ".") {
+		if !identifier.MatchString(part) {
+			return "", fmt.Errorf("invalid function name %q", fn)
+		}
+	}
+
+	names := []string{}
+	for name := range args {
+		if !identifier.MatchString(name) {
+			return "", fmt.Errorf("invalid argument name %q for %s", name, fn)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{}
+	for _, name := range names {
+		lit, err := literal(args[name])
+		if err != nil {
+			return "", fmt.Errorf("could not encode argument %q for %s: %w", name, fn, err)
+		}
+		parts = append(parts, name+": "+lit)
+	}
+	return fn + "(" + strings.Join(parts, ", ") + ")", nil
+}
+
+// literal returns v as a literal of iTerm2's expression language:
+// a string, number, boolean, null or an array of those.
+func literal(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return quote(v)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+	items := []string{}
+	for i := range rv.Len() {
+		item, err := literal(rv.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+	}
+	return "[" + strings.Join(items, ", ") + "]", nil
+}
+
+// quote returns s as a string literal. iTerm2 only knows a few of
+// JSON's escapes, and reads \( as the start of an interpolated
+// expression, so every backslash is escaped. Other characters,
+// including non-ASCII ones such as U+2028, are written as they are.
+// Control characters without an escape are refused.
+func quote(s string) (string, error) {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if unicode.IsControl(r) {
+				return "", fmt.Errorf("unsupported control character %U", r)
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+// Invoke calls the function fn with args in the context of the app,
+// such as iterm2.get_string(title: "Name"), and returns its result.
+// Functions registered with RegisterRPC can be called too. A zero
+// timeout uses iTerm2's default.
+func (a *App) Invoke(fn string, args map[string]any, timeout time.Duration) (Value, error) {
+	return invokeFunction(a.c, &api.InvokeFunctionRequest{
+		Context: &api.InvokeFunctionRequest_App_{
+			App: &api.InvokeFunctionRequest_App{},
+		},
+	}, fn, args, timeout)
+}
+
+// Invoke calls the function fn with args in the context of the
+// window, see App.Invoke.
+func (w *Window) Invoke(fn string, args map[string]any, timeout time.Duration) (Value, error) {
+	return invokeFunction(w.c, &api.InvokeFunctionRequest{
+		Context: &api.InvokeFunctionRequest_Window_{
+			Window: &api.InvokeFunctionRequest_Window{WindowId: &w.id},
+		},
+	}, fn, args, timeout)
+}
+
+// Invoke calls the function fn with args in the context of the tab,
+// see App.Invoke.
+func (t *Tab) Invoke(fn string, args map[string]any, timeout time.Duration) (Value, error) {
+	return invokeFunction(t.c, &api.InvokeFunctionRequest{
+		Context: &api.InvokeFunctionRequest_Tab_{
+			Tab: &api.InvokeFunctionRequest_Tab{TabId: &t.id},
+		},
+	}, fn, args, timeout)
+}
+
+// Invoke calls the function fn with args in the context of the
+// session, see App.Invoke.
+func (s *Session) Invoke(fn string, args map[string]any, timeout time.Duration) (Value, error) {
+	return invokeFunction(s.c, &api.InvokeFunctionRequest{
+		Context: &api.InvokeFunctionRequest_Session_{
+			Session: &api.InvokeFunctionRequest_Session{SessionId: &s.id},
+		},
+	}, fn, args, timeout)
+}
+
+// invokeMethod calls the function fn with the window, tab or session
+// with the given ID as receiver.
+func invokeMethod(c *client.Client, receiver, fn string, args map[string]any) (Value, error) {
+	return invokeFunction(c, &api.InvokeFunctionRequest{
+		Context: &api.InvokeFunctionRequest_Method_{
+			Method: &api.InvokeFunctionRequest_Method{
+				Receiver: &receiver,
+			},
+		},
+	}, fn, args, 0)
+}
+
+// invokeFunction sets the invocation and timeout of req and sends it.
+func invokeFunction(c *client.Client, req *api.InvokeFunctionRequest, fn string, args map[string]any, timeout time.Duration) (Value, error) {
+	inv, err := invocation(fn, args)
+	if err != nil {
+		return nil, err
+	}
+	req.Invocation = &inv
+	if timeout > 0 {
+		seconds := timeout.Seconds()
+		req.Timeout = &seconds
+	}
+	return invoke(c, req)
+}
+
+// invoke sends req and returns the JSON result of the invocation.
+func invoke(c *client.Client, req *api.InvokeFunctionRequest) (Value, error) {
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_InvokeFunctionRequest{
+			InvokeFunctionRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not invoke %s: %w", req.GetInvocation(), err)
+	}
+	ifr := resp.GetInvokeFunctionResponse()
+	if e := ifr.GetError(); e != nil {
+		return nil, fmt.Errorf("error invoking %s: %s: %s", req.GetInvocation(), e.GetStatus(), e.GetErrorReason())
+	}
+	return Value(ifr.GetSuccess().GetJsonResult()), nil
+}
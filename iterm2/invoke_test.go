@@ -0,0 +1,42 @@
+package iterm2
+
+import "testing"
+
+func TestInvocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      string
+		args    map[string]any
+		want    string
+		wantErr bool
+	}{
+		{name: "no arguments", fn: "iterm2.get_title", want: "iterm2.get_title()"},
+		{name: "string", fn: "iterm2.set_title", args: map[string]any{"title": "vim"}, want: `iterm2.set_title(title: "vim")`},
+		{name: "quotes", fn: "f", args: map[string]any{"s": `say "hi")`}, want: `f(s: "say \"hi\")")`},
+		{name: "backslashes", fn: "f", args: map[string]any{"s": `C:\dir\`}, want: `f(s: "C:\\dir\\")`},
+		{name: "interpolation", fn: "f", args: map[string]any{"s": `\(session.name)`}, want: `f(s: "\\(session.name)")`},
+		{name: "newlines and tabs", fn: "f", args: map[string]any{"s": "a\nb\tc"}, want: `f(s: "a\nb\tc")`},
+		{name: "non-ASCII", fn: "f", args: map[string]any{"s": "café ✓ 日本"}, want: `f(s: "café ✓ 日本")`},
+		{name: "line separators", fn: "f", args: map[string]any{"s": "a\u2028b\u2029c"}, want: "f(s: \"a\u2028b\u2029c\")"},
+		{name: "HTML characters", fn: "f", args: map[string]any{"s": "<a & b>"}, want: `f(s: "<a & b>")`},
+		{name: "control character", fn: "f", args: map[string]any{"s": "a\x1bb"}, wantErr: true},
+		{name: "carriage return", fn: "f", args: map[string]any{"s": "a\rb"}, wantErr: true},
+		{name: "numbers and booleans", fn: "f", args: map[string]any{"i": 3, "x": 1.5, "b": true, "n": nil}, want: "f(b: true, i: 3, n: null, x: 1.5)"},
+		{name: "array", fn: "f", args: map[string]any{"a": []string{"x", `"y"`}}, want: `f(a: ["x", "\"y\""])`},
+		{name: "unsupported type", fn: "f", args: map[string]any{"m": map[string]int{}}, wantErr: true},
+		{name: "invalid function name", fn: "f(); g", wantErr: true},
+		{name: "empty function name part", fn: "iterm2..f", wantErr: true},
+		{name: "invalid argument name", fn: "f", args: map[string]any{"a: 1, b": 2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := invocation(tt.fn, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("invocation() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("invocation() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package iterm2
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// Modifier is a modifier key held down during a keystroke.
+type Modifier int
+
+// The modifier keys, as reported by iTerm2.
+const (
+	Control  = Modifier(api.Modifiers_CONTROL)
+	Option   = Modifier(api.Modifiers_OPTION)
+	Command  = Modifier(api.Modifiers_COMMAND)
+	Shift    = Modifier(api.Modifiers_SHIFT)
+	Function = Modifier(api.Modifiers_FUNCTION)
+	Numpad   = Modifier(api.Modifiers_NUMPAD)
+)
+
+func (m Modifier) String() string {
+	switch m {
+	case Control:
+		return "control"
+	case Option:
+		return "option"
+	case Command:
+		return "command"
+	case Shift:
+		return "shift"
+	case Function:
+		return "function"
+	case Numpad:
+		return "numpad"
+	}
+	return "unknown"
+}
+
+// Keystroke is a key pressed in one of iTerm2's sessions.
+type Keystroke struct {
+	// Characters is the text the keystroke produces.
+	Characters string
+	// CharactersIgnoringModifiers is the text the keystroke produces
+	// without modifiers, except for shift.
+	CharactersIgnoringModifiers string
+	Modifiers                   []Modifier
+	KeyCode                     int
+	SessionID                   string
+}
+
+// KeystrokePattern matches keystrokes that have all the required and
+// none of the forbidden modifiers, and any of the key codes, characters
+// or characters ignoring modifiers.
+type KeystrokePattern struct {
+	RequiredModifiers           []Modifier
+	ForbiddenModifiers          []Modifier
+	KeyCodes                    []int
+	Characters                  []string
+	CharactersIgnoringModifiers []string
+}
+
+// Matches reports whether the keystroke matches the pattern, the same
+// way iTerm2 does for the filter of WatchKeystrokes.
+func (p KeystrokePattern) Matches(k Keystroke) bool {
+	for _, m := range p.RequiredModifiers {
+		if !slices.Contains(k.Modifiers, m) {
+			return false
+		}
+	}
+	for _, m := range p.ForbiddenModifiers {
+		if slices.Contains(k.Modifiers, m) {
+			return false
+		}
+	}
+	return slices.Contains(p.KeyCodes, k.KeyCode) ||
+		slices.Contains(p.Characters, k.Characters) ||
+		slices.Contains(p.CharactersIgnoringModifiers, k.CharactersIgnoringModifiers)
+}
+
+func (p KeystrokePattern) pattern() *api.KeystrokePattern {
+	kp := &api.KeystrokePattern{
+		Characters:                  p.Characters,
+		CharactersIgnoringModifiers: p.CharactersIgnoringModifiers,
+	}
+	for _, m := range p.RequiredModifiers {
+		kp.RequiredModifiers = append(kp.RequiredModifiers, api.Modifiers(m))
+	}
+	for _, m := range p.ForbiddenModifiers {
+		kp.ForbiddenModifiers = append(kp.ForbiddenModifiers, api.Modifiers(m))
+	}
+	for _, code := range p.KeyCodes {
+		kp.Keycodes = append(kp.Keycodes, int32(code))
+	}
+	return kp
+}
+
+// WatchKeystrokes streams the keys pressed in all sessions until ctx
+// is done. Only key-down events are sent. Keystrokes that match any of
+// the filter patterns are kept from iTerm2's own handling, so the
+// caller can handle them instead.
+//
+// iTerm2 takes the filter as a request of its own, but it shares the
+// keystroke subscription's stream rather than tapping another one.
+func (a *App) WatchKeystrokes(ctx context.Context, filter ...KeystrokePattern) (<-chan Keystroke, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	session := "all"
+	notifications, err := subscribe(ctx, a.c, &api.NotificationRequest{
+		Session:          &session,
+		NotificationType: api.NotificationType_NOTIFY_ON_KEYSTROKE.Enum(),
+		Arguments: &api.NotificationRequest_KeystrokeMonitorRequest{
+			KeystrokeMonitorRequest: &api.KeystrokeMonitorRequest{},
+		},
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if len(filter) > 0 {
+		fr := &api.KeystrokeFilterRequest{}
+		for _, p := range filter {
+			fr.PatternsToIgnore = append(fr.PatternsToIgnore, p.pattern())
+		}
+		req := &api.NotificationRequest{
+			Session:          &session,
+			NotificationType: api.NotificationType_NOTIFY_ON_KEYSTROKE.Enum(),
+			Arguments: &api.NotificationRequest_KeystrokeFilterRequest{
+				KeystrokeFilterRequest: fr,
+			},
+		}
+		err := notificationRequest(a.c, req, true)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		go func() {
+			<-ctx.Done()
+			err := unsubscribe(a.c, req)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	ch := make(chan Keystroke)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		for n := range notifications {
+			kn := n.GetKeystrokeNotification()
+			if kn == nil || kn.GetAction() != api.KeystrokeNotification_KEY_DOWN {
+				continue
+			}
+			k := Keystroke{
+				Characters:                  kn.GetCharacters(),
+				CharactersIgnoringModifiers: kn.GetCharactersIgnoringModifiers(),
+				KeyCode:                     int(kn.GetKeyCode()),
+				SessionID:                   kn.GetSession(),
+			}
+			for _, m := range kn.GetModifiers() {
+				k.Modifiers = append(k.Modifiers, Modifier(m))
+			}
+			select {
+			case ch <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
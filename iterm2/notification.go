@@ -0,0 +1,65 @@
+package iterm2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// subscribe asks iTerm2 to send the notifications described by req,
+// and returns all notifications received on the connection until ctx
+// is done. Callers filter out the ones they are interested in. When
+// ctx is done, iTerm2 is asked to stop sending them.
+func subscribe(ctx context.Context, c *client.Client, req *api.NotificationRequest) (<-chan *api.Notification, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// tap the stream before asking for notifications, so none are missed
+	notifications := c.Subscribe(ctx)
+	err := notificationRequest(c, req, true)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		cancel()
+		err := unsubscribe(c, req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	return notifications, nil
+}
+
+// unsubscribe asks iTerm2 to stop sending the notifications described
+// by req. A closed connection sends none anyway, so that isn't an
+// error.
+func unsubscribe(c *client.Client, req *api.NotificationRequest) error {
+	err := notificationRequest(c, req, false)
+	if errors.Is(err, client.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// notificationRequest subscribes to or unsubscribes from notifications.
+func notificationRequest(c *client.Client, req *api.NotificationRequest, subscribe bool) error {
+	req.Subscribe = &subscribe
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_NotificationRequest{
+			NotificationRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not request %s notifications: %w", req.GetNotificationType(), err)
+	}
+	if status := resp.GetNotificationResponse().GetStatus(); status != api.NotificationResponse_OK {
+		return fmt.Errorf("unexpected status for %s notification request: %s", req.GetNotificationType(), status)
+	}
+	return nil
+}
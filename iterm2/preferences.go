@@ -0,0 +1,46 @@
+package iterm2
+
+import (
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// Preferences returns the values of iTerm2's preferences with the given
+// keys, such as "FocusFollowsMouse", keyed by key. Preferences that are
+// not set and have no default are null Values.
+func (a *App) Preferences(keys ...string) (map[string]Value, error) {
+	req := &api.PreferencesRequest{}
+	for _, key := range keys {
+		req.Requests = append(req.Requests, &api.PreferencesRequest_Request{
+			Request: &api.PreferencesRequest_Request_GetPreferenceRequest{
+				GetPreferenceRequest: &api.PreferencesRequest_Request_GetPreference{
+					Key: &key,
+				},
+			},
+		})
+	}
+
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_PreferencesRequest{
+			PreferencesRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get preferences: %w", err)
+	}
+	results := resp.GetPreferencesResponse().GetResults()
+	if len(results) != len(keys) {
+		return nil, fmt.Errorf("unexpected number of preferences: got %d, want %d", len(results), len(keys))
+	}
+
+	prefs := make(map[string]Value, len(keys))
+	for i, r := range results {
+		gpr := r.GetGetPreferenceResult()
+		if gpr == nil {
+			return nil, fmt.Errorf("preference %q was not recognized", keys[i])
+		}
+		prefs[keys[i]] = Value(gpr.GetJsonValue())
+	}
+	return prefs, nil
+}
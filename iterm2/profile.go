@@ -0,0 +1,200 @@
+package iterm2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// Profile is an iTerm2 profile, holding the properties that were
+// requested when it was fetched. Property keys are the ones iTerm2
+// uses in its preferences, such as "Name", "Guid" or "Badge Text".
+type Profile struct {
+	c *client.Client
+	// session is set when this is a session's copy of a profile
+	session string
+	props   map[string]Value
+}
+
+// ListProfiles returns all profiles. If properties are given, only
+// those properties are fetched, along with "Guid" and "Name".
+func (a *App) ListProfiles(properties ...string) ([]*Profile, error) {
+	return a.listProfiles(properties, nil)
+}
+
+// ProfileByGUID returns the profile with the given GUID.
+func (a *App) ProfileByGUID(guid string, properties ...string) (*Profile, error) {
+	list, err := a.listProfiles(properties, []string{guid})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no profile with guid %q", guid)
+	}
+	return list[0], nil
+}
+
+// ProfileByName returns the first profile with the given name.
+func (a *App) ProfileByName(name string, properties ...string) (*Profile, error) {
+	list, err := a.listProfiles(properties, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no profile named %q", name)
+}
+
+func (a *App) listProfiles(properties, guids []string) ([]*Profile, error) {
+	if len(properties) > 0 {
+		properties = append(append([]string{}, properties...), "Guid", "Name")
+	}
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ListProfilesRequest{
+			ListProfilesRequest: &api.ListProfilesRequest{
+				Properties: properties,
+				Guids:      guids,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list profiles: %w", err)
+	}
+
+	list := []*Profile{}
+	for _, p := range resp.GetListProfilesResponse().GetProfiles() {
+		props, err := profileProperties(p.GetProperties())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &Profile{c: a.c, props: props})
+	}
+	return list, nil
+}
+
+// Profile returns the session's copy of its profile, which includes
+// changes made to the session only. If keys are given, only those
+// properties are fetched.
+func (s *Session) Profile(keys ...string) (*Profile, error) {
+	resp, err := s.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_GetProfilePropertyRequest{
+			GetProfilePropertyRequest: &api.GetProfilePropertyRequest{
+				Session: &s.id,
+				Keys:    keys,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting profile of session %q: %w", s.id, err)
+	}
+	gpr := resp.GetGetProfilePropertyResponse()
+	if status := gpr.GetStatus(); status != api.GetProfilePropertyResponse_OK {
+		return nil, fmt.Errorf("unexpected status for get profile property request: %s", status)
+	}
+	props, err := profileProperties(gpr.GetProperties())
+	if err != nil {
+		return nil, err
+	}
+	return &Profile{c: s.c, session: s.id, props: props}, nil
+}
+
+// SetProfileProperties changes properties of the session's copy of its
+// profile, without changing the profile itself, see
+// Profile.SetProperties.
+func (s *Session) SetProfileProperties(props map[string]any) error {
+	p := &Profile{c: s.c, session: s.id, props: map[string]Value{}}
+	return p.SetProperties(props)
+}
+
+func profileProperties(list []*api.ProfileProperty) (map[string]Value, error) {
+	props := make(map[string]Value, len(list))
+	for _, p := range list {
+		v := Value(p.GetJsonValue())
+		if !json.Valid(v) {
+			return nil, fmt.Errorf("invalid JSON value for profile property %q: %s", p.GetKey(), v)
+		}
+		props[p.GetKey()] = v
+	}
+	return props, nil
+}
+
+func (p *Profile) GUID() string {
+	return p.props["Guid"].String()
+}
+
+func (p *Profile) Name() string {
+	return p.props["Name"].String()
+}
+
+// Property returns the value of a property. It is a null Value if
+// the property is unset or was not fetched.
+func (p *Profile) Property(key string) Value {
+	return p.props[key]
+}
+
+// Properties returns the keys of all fetched properties.
+func (p *Profile) Properties() []string {
+	list := make([]string, 0, len(p.props))
+	for k := range p.props {
+		list = append(list, k)
+	}
+	return list
+}
+
+// SetProperty sets a property, see SetProperties.
+func (p *Profile) SetProperty(key string, value any) error {
+	return p.SetProperties(map[string]any{key: value})
+}
+
+// SetProperties sets properties, encoding the values as JSON. For a
+// session's copy of a profile only that session is changed, otherwise
+// the profile itself is changed.
+func (p *Profile) SetProperties(props map[string]any) error {
+	req := &api.SetProfilePropertyRequest{}
+	if p.session != "" {
+		req.Target = &api.SetProfilePropertyRequest_Session{
+			Session: p.session,
+		}
+	} else {
+		req.Target = &api.SetProfilePropertyRequest_GuidList_{
+			GuidList: &api.SetProfilePropertyRequest_GuidList{
+				Guids: []string{p.GUID()},
+			},
+		}
+	}
+
+	encoded := make(map[string]Value, len(props))
+	for key, value := range props {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("could not marshal profile property %q: %w", key, err)
+		}
+		encoded[key] = b
+		req.Assignments = append(req.Assignments, &api.SetProfilePropertyRequest_Assignment{
+			Key:       str(key),
+			JsonValue: str(string(b)),
+		})
+	}
+
+	resp, err := p.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_SetProfilePropertyRequest{
+			SetProfilePropertyRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not set profile properties: %w", err)
+	}
+	if status := resp.GetSetProfilePropertyResponse().GetStatus(); status != api.SetProfilePropertyResponse_OK {
+		return fmt.Errorf("unexpected status for set profile property request: %s", status)
+	}
+
+	for key, v := range encoded {
+		p.props[key] = v
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package iterm2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// PromptState tells where a prompt is in its lifecycle.
+type PromptState int
+
+const (
+	// PromptEditing means the shell is idle at the prompt: the
+	// command is still being typed.
+	PromptEditing PromptState = iota
+	// PromptRunning means the command was entered and is running.
+	PromptRunning
+	// PromptFinished means the command has exited.
+	PromptFinished
+)
+
+func (s PromptState) String() string {
+	switch s {
+	case PromptEditing:
+		return "editing"
+	case PromptRunning:
+		return "running"
+	case PromptFinished:
+		return "finished"
+	}
+	return "unknown"
+}
+
+// Prompt describes a shell prompt and the command entered at it.
+// Prompts are only available in sessions with shell integration
+// installed.
+type Prompt struct {
+	ID               string
+	State            PromptState
+	Command          string
+	WorkingDirectory string
+	// ExitStatus is only set when State is PromptFinished.
+	ExitStatus int
+}
+
+// CurrentPrompt returns the most recent prompt of the session. It
+// returns nil without an error if no prompt is available, for example
+// because shell integration is not installed.
+func (s *Session) CurrentPrompt() (*Prompt, error) {
+	return s.getPrompt(nil)
+}
+
+// Prompt returns the prompt with the given ID, as returned by
+// ListPrompts. It returns nil without an error if the prompt is no
+// longer available.
+func (s *Session) Prompt(id string) (*Prompt, error) {
+	return s.getPrompt(&id)
+}
+
+func (s *Session) getPrompt(id *string) (*Prompt, error) {
+	resp, err := s.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_GetPromptRequest{
+			GetPromptRequest: &api.GetPromptRequest{
+				Session:        &s.id,
+				UniquePromptId: id,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting prompt of session %q: %w", s.id, err)
+	}
+	gpr := resp.GetGetPromptResponse()
+	switch status := gpr.GetStatus(); status {
+	case api.GetPromptResponse_OK:
+	case api.GetPromptResponse_PROMPT_UNAVAILABLE:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status for get prompt request: %s", status)
+	}
+	return newPrompt(gpr), nil
+}
+
+// ListPrompts returns the IDs of the session's prompts, oldest first.
+func (s *Session) ListPrompts() ([]string, error) {
+	resp, err := s.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ListPromptsRequest{
+			ListPromptsRequest: &api.ListPromptsRequest{
+				Session: &s.id,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing prompts of session %q: %w", s.id, err)
+	}
+	lpr := resp.GetListPromptsResponse()
+	if status := lpr.GetStatus(); status != api.ListPromptsResponse_OK {
+		return nil, fmt.Errorf("unexpected status for list prompts request: %s", status)
+	}
+	return lpr.GetUniquePromptId(), nil
+}
+
+func newPrompt(gpr *api.GetPromptResponse) *Prompt {
+	p := &Prompt{
+		ID:               gpr.GetUniquePromptId(),
+		Command:          gpr.GetCommand(),
+		WorkingDirectory: gpr.GetWorkingDirectory(),
+		ExitStatus:       int(gpr.GetExitStatus()),
+	}
+	switch gpr.GetPromptState() {
+	case api.GetPromptResponse_RUNNING:
+		p.State = PromptRunning
+	case api.GetPromptResponse_FINISHED:
+		p.State = PromptFinished
+	}
+	return p
+}
+
+// PromptEventKind tells what happened in a PromptEvent.
+type PromptEventKind int
+
+const (
+	// PromptShown means a new prompt was shown: the shell is idle.
+	PromptShown PromptEventKind = iota
+	// CommandStarted means a command was entered at the prompt.
+	CommandStarted
+	// CommandEnded means the command exited.
+	CommandEnded
+)
+
+func (k PromptEventKind) String() string {
+	switch k {
+	case PromptShown:
+		return "prompt"
+	case CommandStarted:
+		return "command-start"
+	case CommandEnded:
+		return "command-end"
+	}
+	return "unknown"
+}
+
+// PromptEvent is sent by WatchPrompts.
+type PromptEvent struct {
+	Kind      PromptEventKind
+	SessionID string
+	PromptID  string
+	// Prompt is set for PromptShown, when iTerm2 includes it.
+	Prompt *Prompt
+	// Command is set for CommandStarted.
+	Command string
+	// ExitStatus is set for CommandEnded.
+	ExitStatus int
+}
+
+// WatchPrompts streams the prompt events of the session until ctx is
+// done.
+func (s *Session) WatchPrompts(ctx context.Context) (<-chan PromptEvent, error) {
+	return watchPrompts(ctx, s.c, s.id)
+}
+
+// WatchPrompts streams the prompt events of all sessions until ctx is
+// done.
+func (a *App) WatchPrompts(ctx context.Context) (<-chan PromptEvent, error) {
+	return watchPrompts(ctx, a.c, "all")
+}
+
+func watchPrompts(ctx context.Context, c *client.Client, session string) (<-chan PromptEvent, error) {
+	notifications, err := subscribe(ctx, c, &api.NotificationRequest{
+		Session:          &session,
+		NotificationType: api.NotificationType_NOTIFY_ON_PROMPT.Enum(),
+		Arguments: &api.NotificationRequest_PromptMonitorRequest{
+			PromptMonitorRequest: &api.PromptMonitorRequest{
+				Modes: []api.PromptMonitorMode{
+					api.PromptMonitorMode_PROMPT,
+					api.PromptMonitorMode_COMMAND_START,
+					api.PromptMonitorMode_COMMAND_END,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan PromptEvent)
+	go func() {
+		defer close(ch)
+		for n := range notifications {
+			pn := n.GetPromptNotification()
+			if pn == nil || (session != "all" && pn.GetSession() != session) {
+				continue
+			}
+			e := PromptEvent{
+				SessionID: pn.GetSession(),
+				PromptID:  pn.GetUniquePromptId(),
+			}
+			switch ev := pn.GetEvent().(type) {
+			case *api.PromptNotification_Prompt:
+				e.Kind = PromptShown
+				if p := ev.Prompt.GetPrompt(); p != nil {
+					e.Prompt = newPrompt(p)
+				}
+			case *api.PromptNotification_CommandStart:
+				e.Kind = CommandStarted
+				e.Command = ev.CommandStart.GetCommand()
+			case *api.PromptNotification_CommandEnd:
+				e.Kind = CommandEnded
+				e.ExitStatus = int(ev.CommandEnd.GetStatus())
+			default:
+				continue
+			}
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
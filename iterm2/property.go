@@ -0,0 +1,143 @@
+package iterm2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// Point is a position on screen, in points. Points can be fractional
+// on Retina screens.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Size is the size of a window in points, or of a session's grid in
+// cells. Only window sizes can be fractional.
+type Size struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Frame is the position and size of a window. The origin is the
+// bottom left corner of the main screen, as in macOS.
+type Frame struct {
+	Origin Point `json:"origin"`
+	Size   Size  `json:"size"`
+}
+
+// Frame returns the position and size of the window.
+func (w *Window) Frame() (Frame, error) {
+	var f Frame
+	err := getProperty(w.c, w.getPropertyRequest("frame"), &f)
+	return f, err
+}
+
+// SetFrame moves and resizes the window.
+func (w *Window) SetFrame(f Frame) error {
+	return setProperty(w.c, w.setPropertyRequest("frame"), f)
+}
+
+// Fullscreen reports whether the window is in full screen mode.
+func (w *Window) Fullscreen() (bool, error) {
+	var b bool
+	err := getProperty(w.c, w.getPropertyRequest("fullscreen"), &b)
+	return b, err
+}
+
+// SetFullscreen enters or exits full screen mode.
+func (w *Window) SetFullscreen(fullscreen bool) error {
+	return setProperty(w.c, w.setPropertyRequest("fullscreen"), fullscreen)
+}
+
+func (w *Window) getPropertyRequest(name string) *api.GetPropertyRequest {
+	return &api.GetPropertyRequest{
+		Identifier: &api.GetPropertyRequest_WindowId{
+			WindowId: w.id,
+		},
+		Name: &name,
+	}
+}
+
+func (w *Window) setPropertyRequest(name string) *api.SetPropertyRequest {
+	return &api.SetPropertyRequest{
+		Identifier: &api.SetPropertyRequest_WindowId{
+			WindowId: w.id,
+		},
+		Name: &name,
+	}
+}
+
+// GridSize returns the number of columns (Width) and rows (Height)
+// of the session.
+func (s *Session) GridSize() (Size, error) {
+	var size Size
+	err := getProperty(s.c, s.getPropertyRequest("grid_size"), &size)
+	return size, err
+}
+
+// SetGridSize resizes the session to the given number of columns
+// (Width) and rows (Height). The window is resized to fit.
+func (s *Session) SetGridSize(size Size) error {
+	return setProperty(s.c, s.setPropertyRequest("grid_size"), size)
+}
+
+func (s *Session) getPropertyRequest(name string) *api.GetPropertyRequest {
+	return &api.GetPropertyRequest{
+		Identifier: &api.GetPropertyRequest_SessionId{
+			SessionId: s.id,
+		},
+		Name: &name,
+	}
+}
+
+func (s *Session) setPropertyRequest(name string) *api.SetPropertyRequest {
+	return &api.SetPropertyRequest{
+		Identifier: &api.SetPropertyRequest_SessionId{
+			SessionId: s.id,
+		},
+		Name: &name,
+	}
+}
+
+func getProperty(c *client.Client, req *api.GetPropertyRequest, dst any) error {
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_GetPropertyRequest{
+			GetPropertyRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not get property %q: %w", req.GetName(), err)
+	}
+	gpr := resp.GetGetPropertyResponse()
+	if status := gpr.GetStatus(); status != api.GetPropertyResponse_OK {
+		return fmt.Errorf("unexpected status for get property %q: %s", req.GetName(), status)
+	}
+	if err := json.Unmarshal([]byte(gpr.GetJsonValue()), dst); err != nil {
+		return fmt.Errorf("could not unmarshal property %q: %w", req.GetName(), err)
+	}
+	return nil
+}
+
+func setProperty(c *client.Client, req *api.SetPropertyRequest, value any) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not marshal property %q: %w", req.GetName(), err)
+	}
+	req.JsonValue = str(string(b))
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_SetPropertyRequest{
+			SetPropertyRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not set property %q: %w", req.GetName(), err)
+	}
+	if status := resp.GetSetPropertyResponse().GetStatus(); status != api.SetPropertyResponse_OK {
+		return fmt.Errorf("unexpected status for set property %q: %s", req.GetName(), status)
+	}
+	return nil
+}
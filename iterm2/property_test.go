@@ -0,0 +1,18 @@
+package iterm2
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFrameUnmarshal(t *testing.T) {
+	var f Frame
+	err := json.Unmarshal([]byte(`{"origin":{"x":12.5,"y":-3},"size":{"width":800.25,"height":600}}`), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Frame{Origin: Point{X: 12.5, Y: -3}, Size: Size{Width: 800.25, Height: 600}}
+	if f != want {
+		t.Errorf("frame = %+v, want %+v", f, want)
+	}
+}
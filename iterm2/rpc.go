@@ -0,0 +1,141 @@
+package iterm2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// RPC describes a function that iTerm2 can call, for example from a
+// key binding's "Invoke Script Function" action:
+//
+//	iterm2_toggle(target: "vim")
+type RPC struct {
+	// Name is the name of the function.
+	Name string
+	// Arguments are the names of the function's arguments.
+	Arguments []string
+	// Defaults maps argument names to the variables whose values are
+	// passed when the caller leaves them out, such as "session.id".
+	Defaults map[string]string
+	// Timeout is how long iTerm2 waits for the result. iTerm2 picks
+	// the timeout when zero.
+	Timeout time.Duration
+}
+
+// RPCHandler handles a call of a registered function. args holds the
+// JSON encoded arguments by name. The result is encoded as JSON; an
+// error is reported to iTerm2 as an exception.
+type RPCHandler func(args map[string]Value) (any, error)
+
+// RegisterRPC registers a function with iTerm2 and calls handler for
+// each call of it, until ctx is done. Calls are handled one at a time.
+func (a *App) RegisterRPC(ctx context.Context, rpc RPC, handler RPCHandler) error {
+	return registerRPC(ctx, a.c, rpc.registrationRequest(), handler)
+}
+
+func (rpc RPC) registrationRequest() *api.RPCRegistrationRequest {
+	req := &api.RPCRegistrationRequest{
+		Name: &rpc.Name,
+		Role: api.RPCRegistrationRequest_GENERIC.Enum(),
+	}
+	for _, name := range rpc.Arguments {
+		req.Arguments = append(req.Arguments, &api.RPCRegistrationRequest_RPCArgumentSignature{
+			Name: &name,
+		})
+	}
+
+	// sort for a stable signature
+	names := []string{}
+	for name := range rpc.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := rpc.Defaults[name]
+		req.Defaults = append(req.Defaults, &api.RPCRegistrationRequest_RPCArgument{
+			Name: &name,
+			Path: &path,
+		})
+	}
+
+	if rpc.Timeout > 0 {
+		timeout := float32(rpc.Timeout.Seconds())
+		req.Timeout = &timeout
+	}
+	return req
+}
+
+// registerRPC registers the function described by req, whatever its
+// role, and answers its calls with handler until ctx is done.
+func registerRPC(ctx context.Context, c *client.Client, req *api.RPCRegistrationRequest, handler RPCHandler) error {
+	notifications, err := subscribe(ctx, c, &api.NotificationRequest{
+		NotificationType: api.NotificationType_NOTIFY_ON_SERVER_ORIGINATED_RPC.Enum(),
+		Arguments: &api.NotificationRequest_RpcRegistrationRequest{
+			RpcRegistrationRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not register function %q: %w", req.GetName(), err)
+	}
+
+	go func() {
+		for n := range notifications {
+			call := n.GetServerOriginatedRpcNotification()
+			if call == nil || call.GetRpc().GetName() != req.GetName() {
+				continue
+			}
+
+			args := map[string]Value{}
+			for _, arg := range call.GetRpc().GetArguments() {
+				args[arg.GetName()] = Value(arg.GetJsonValue())
+			}
+			result, err := handler(args)
+			err = rpcResult(c, call.GetRequestId(), result, err)
+			if err != nil && !errors.Is(err, client.ErrClosed) {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// rpcResult sends the result of a function call, or the error it
+// failed with, back to iTerm2.
+func rpcResult(c *client.Client, requestID string, result any, callErr error) error {
+	req := &api.ServerOriginatedRPCResultRequest{
+		RequestId: &requestID,
+	}
+	if callErr == nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			callErr = fmt.Errorf("could not encode result: %w", err)
+		} else {
+			req.Result = &api.ServerOriginatedRPCResultRequest_JsonValue{JsonValue: string(b)}
+		}
+	}
+	if callErr != nil {
+		b, err := json.Marshal(map[string]string{"reason": callErr.Error()})
+		if err != nil {
+			return err
+		}
+		req.Result = &api.ServerOriginatedRPCResultRequest_JsonException{JsonException: string(b)}
+	}
+
+	_, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ServerOriginatedRpcResultRequest{
+			ServerOriginatedRpcResultRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not send result of call %q: %w", requestID, err)
+	}
+	return nil
+}
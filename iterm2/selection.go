@@ -0,0 +1,167 @@
+package iterm2
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// Coord is the location of a cell. Y counts lines from the start of
+// the scrollback history, including lines that were dropped from it.
+type Coord struct {
+	X int
+	Y int64
+}
+
+// WindowedCoordRange is a range of cells from Start up to, but not
+// including, End. When Columns.Length is not 0, only the cells in
+// those columns are part of the range, as for a box selection.
+type WindowedCoordRange struct {
+	Start   Coord
+	End     Coord
+	Columns ColumnRange
+}
+
+// ColumnRange is a range of Length columns starting at Location.
+type ColumnRange struct {
+	Location int
+	Length   int
+}
+
+// SelectionMode is how a sub-selection was made.
+type SelectionMode int
+
+// The selection modes, by what a click or drag selects.
+const (
+	SelectCharacter = SelectionMode(api.SelectionMode_CHARACTER)
+	SelectWord      = SelectionMode(api.SelectionMode_WORD)
+	SelectLine      = SelectionMode(api.SelectionMode_LINE)
+	SelectSmart     = SelectionMode(api.SelectionMode_SMART)
+	SelectBox       = SelectionMode(api.SelectionMode_BOX)
+	SelectWholeLine = SelectionMode(api.SelectionMode_WHOLE_LINE)
+)
+
+// SubSelection is a contiguous part of a selection.
+type SubSelection struct {
+	Range WindowedCoordRange
+	Mode  SelectionMode
+	// Connected joins the sub-selection to the next one without a
+	// newline in between.
+	Connected bool
+}
+
+// Selection returns the session's selected text ranges. It is empty
+// when nothing is selected.
+func (s *Session) Selection() ([]SubSelection, error) {
+	sr, err := s.selectionRequest(&api.SelectionRequest{
+		Request: &api.SelectionRequest_GetSelectionRequest_{
+			GetSelectionRequest: &api.SelectionRequest_GetSelectionRequest{
+				SessionId: &s.id,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := []SubSelection{}
+	for _, sub := range sr.GetGetSelectionResponse().GetSelection().GetSubSelections() {
+		wcr := sub.GetWindowedCoordRange()
+		cr := wcr.GetCoordRange()
+		list = append(list, SubSelection{
+			Range: WindowedCoordRange{
+				Start: Coord{X: int(cr.GetStart().GetX()), Y: cr.GetStart().GetY()},
+				End:   Coord{X: int(cr.GetEnd().GetX()), Y: cr.GetEnd().GetY()},
+				Columns: ColumnRange{
+					Location: int(wcr.GetColumns().GetLocation()),
+					Length:   int(wcr.GetColumns().GetLength()),
+				},
+			},
+			Mode:      SelectionMode(sub.GetSelectionMode()),
+			Connected: sub.GetConnected(),
+		})
+	}
+	return list, nil
+}
+
+// SetSelection replaces the session's selection. Passing no
+// sub-selections clears it.
+func (s *Session) SetSelection(subs []SubSelection) error {
+	sel := &api.Selection{}
+	for _, sub := range subs {
+		sel.SubSelections = append(sel.SubSelections, &api.SubSelection{
+			WindowedCoordRange: sub.Range.windowedCoordRange(),
+			SelectionMode:      api.SelectionMode(sub.Mode).Enum(),
+			Connected:          &sub.Connected,
+		})
+	}
+	_, err := s.selectionRequest(&api.SelectionRequest{
+		Request: &api.SelectionRequest_SetSelectionRequest_{
+			SetSelectionRequest: &api.SelectionRequest_SetSelectionRequest{
+				SessionId: &s.id,
+				Selection: sel,
+			},
+		},
+	})
+	return err
+}
+
+// SelectedText returns the text of the session's selection, or "" if
+// nothing is selected.
+func (s *Session) SelectedText() (string, error) {
+	subs, err := s.Selection()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, sub := range subs {
+		r := sub.Range
+		lines, err := s.GetBuffer(LineRange{Coords: &r})
+		if err != nil {
+			return "", err
+		}
+		for j, l := range lines {
+			b.WriteString(l.Text)
+			if j < len(lines)-1 && !l.SoftWrapped {
+				b.WriteByte('\n')
+			}
+		}
+		if i < len(subs)-1 && !sub.Connected {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
+
+func (r WindowedCoordRange) windowedCoordRange() *api.WindowedCoordRange {
+	startX, endX := int32(r.Start.X), int32(r.End.X)
+	wcr := &api.WindowedCoordRange{
+		CoordRange: &api.CoordRange{
+			Start: &api.Coord{X: &startX, Y: &r.Start.Y},
+			End:   &api.Coord{X: &endX, Y: &r.End.Y},
+		},
+	}
+	if r.Columns.Length != 0 {
+		location, length := int64(r.Columns.Location), int64(r.Columns.Length)
+		wcr.Columns = &api.Range{Location: &location, Length: &length}
+	}
+	return wcr
+}
+
+func (s *Session) selectionRequest(req *api.SelectionRequest) (*api.SelectionResponse, error) {
+	resp, err := s.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_SelectionRequest{
+			SelectionRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error sending selection request for session %q: %w", s.id, err)
+	}
+	sr := resp.GetSelectionResponse()
+	if status := sr.GetStatus(); status != api.SelectionResponse_OK {
+		return nil, fmt.Errorf("unexpected status for selection request: %s", status)
+	}
+	return sr, nil
+}
@@ -1,11 +1,11 @@
 package iterm2
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 
-	"marwan.io/iterm2/api"
-	"marwan.io/iterm2/client"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
 )
 
 // SplitPaneOptions for customizing the new pane session.
@@ -14,6 +14,11 @@ type SplitPaneOptions struct {
 	Vertical bool
 }
 
+// ErrNotRestartable is returned by Session.Restart for sessions that
+// can't be restarted, such as tmux integration sessions, or sessions
+// that are still running when onlyIfExited is set.
+var ErrNotRestartable = errors.New("session is not restartable")
+
 type Session struct {
 	c  *client.Client
 	id string
@@ -37,19 +42,42 @@ func (s *Session) SendText(t string) error {
 	return nil
 }
 
+// ActivateOptions for customizing how a session or window is brought
+// forward.
+type ActivateOptions struct {
+	// SelectTab selects the tab containing the session.
+	SelectTab bool
+	// OrderWindowFront orders the window in front of other iTerm2 windows.
+	OrderWindowFront bool
+	// ActivateApp also makes iTerm2 the active application.
+	ActivateApp bool
+	// RaiseAllWindows raises all iTerm2 windows, not just the one that
+	// is activated. Only used with ActivateApp.
+	RaiseAllWindows bool
+	// IgnoringOtherApps activates iTerm2 even when another application
+	// is active. Only used with ActivateApp.
+	IgnoringOtherApps bool
+}
+
 func (s *Session) Activate(selectTab, orderWindowFront bool) error {
-	selectionSession := true
+	return s.ActivateWithOptions(ActivateOptions{
+		SelectTab:        selectTab,
+		OrderWindowFront: orderWindowFront,
+	})
+}
+
+func (s *Session) ActivateWithOptions(opts ActivateOptions) error {
+	selectSession := true
+
+	req := activateRequest(opts)
+	req.Identifier = &api.ActivateRequest_SessionId{
+		SessionId: s.id,
+	}
+	req.SelectSession = &selectSession
 
 	resp, err := s.c.Call(&api.ClientOriginatedMessage{
 		Submessage: &api.ClientOriginatedMessage_ActivateRequest{
-			ActivateRequest: &api.ActivateRequest{
-				Identifier: &api.ActivateRequest_SessionId{
-					SessionId: s.id,
-				},
-				SelectTab:        &selectTab,
-				OrderWindowFront: &orderWindowFront,
-				SelectSession:    &selectionSession,
-			},
+			ActivateRequest: req,
 		},
 	})
 	if err != nil {
@@ -61,6 +89,21 @@ func (s *Session) Activate(selectTab, orderWindowFront bool) error {
 	return nil
 }
 
+// activateRequest builds an ActivateRequest without an identifier.
+func activateRequest(opts ActivateOptions) *api.ActivateRequest {
+	req := &api.ActivateRequest{
+		SelectTab:        &opts.SelectTab,
+		OrderWindowFront: &opts.OrderWindowFront,
+	}
+	if opts.ActivateApp {
+		req.ActivateApp = &api.ActivateRequest_App{
+			RaiseAllWindows:   &opts.RaiseAllWindows,
+			IgnoringOtherApps: &opts.IgnoringOtherApps,
+		}
+	}
+	return req
+}
+
 func (s *Session) SplitPane(opts SplitPaneOptions) (*Session, error) {
 	direction := api.SplitPaneRequest_HORIZONTAL.Enum()
 	if opts.Vertical {
@@ -87,49 +130,130 @@ func (s *Session) SplitPane(opts SplitPaneOptions) (*Session, error) {
 	}, nil
 }
 
-func (s *Session) GetSessionID() string {
-	return s.id
+// Inject feeds data to the session's terminal as if the session's
+// program wrote it, so escape sequences in it take effect. The program
+// doesn't see it.
+func (s *Session) Inject(data []byte) error {
+	resp, err := s.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_InjectRequest{
+			InjectRequest: &api.InjectRequest{
+				SessionId: []string{s.id},
+				Data:      data,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error injecting into session %q: %w", s.id, err)
+	}
+	for _, status := range resp.GetInjectResponse().GetStatus() {
+		if status != api.InjectResponse_OK {
+			return fmt.Errorf("unexpected status for inject request: %s", status)
+		}
+	}
+	return nil
 }
 
-func (s *Session) VariablesGet(vars []string) (map[string]string, error) {
+// Restart restarts the session's program. If onlyIfExited is set, a
+// session that is still running is left alone and ErrNotRestartable
+// is returned; otherwise its program is killed first.
+func (s *Session) Restart(onlyIfExited bool) error {
 	resp, err := s.c.Call(&api.ClientOriginatedMessage{
-		Submessage: &api.ClientOriginatedMessage_VariableRequest{
-			VariableRequest: &api.VariableRequest{
-				Scope: &api.VariableRequest_SessionId{
-					SessionId: s.id,
-				},
-				Get: vars,
+		Submessage: &api.ClientOriginatedMessage_RestartSessionRequest{
+			RestartSessionRequest: &api.RestartSessionRequest{
+				SessionId:    &s.id,
+				OnlyIfExited: &onlyIfExited,
 			},
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("could not get variables: %w", err)
+		return fmt.Errorf("error restarting session %q: %w", s.id, err)
 	}
+	switch status := resp.GetRestartSessionResponse().GetStatus(); status {
+	case api.RestartSessionResponse_OK:
+		return nil
+	case api.RestartSessionResponse_SESSION_NOT_RESTARTABLE:
+		return fmt.Errorf("error restarting session %q: %w", s.id, ErrNotRestartable)
+	default:
+		return fmt.Errorf("unexpected status for restart request: %s", status)
+	}
+}
 
-	if resp.GetVariableResponse().GetStatus() != api.VariableResponse_OK {
-		return nil, fmt.Errorf("unexpected get variable status: %s", resp.GetVariableResponse().GetStatus())
+func (s *Session) GetSessionID() string {
+	return s.id
+}
+
+// VariablesGet returns the values of the given session variables,
+// keyed by name. Unset variables are null Values.
+func (s *Session) VariablesGet(vars []string) (map[string]Value, error) {
+	return variablesGet(s.c, s.variableRequest(), vars)
+}
+
+// VariablesSet sets session variables. Names must begin with "user.",
+// values are encoded as JSON; a nil value unsets the variable.
+func (s *Session) VariablesSet(vars map[string]any) error {
+	return variablesSet(s.c, s.variableRequest(), vars)
+}
+
+func (s *Session) variableRequest() *api.VariableRequest {
+	return &api.VariableRequest{
+		Scope: &api.VariableRequest_SessionId{
+			SessionId: s.id,
+		},
 	}
+}
 
-	// json decode the values
-	m := map[string]string{}
-	values := resp.GetVariableResponse().GetValues()
-	for i, v := range values {
-		s := ""
-		err := json.Unmarshal([]byte(v), &s)
-		if err == nil {
-			key := vars[i]
-			m[key] = s
-			continue
-		}
+// LineRange selects the lines returned by GetBuffer. The zero value
+// selects the lines currently visible on screen.
+type LineRange struct {
+	// Trailing selects the last Trailing lines of the buffer, which
+	// can go back into scrollback history.
+	Trailing int
+	// Coords, when set, selects the cells in this range instead.
+	Coords *WindowedCoordRange
+}
 
-		// it probably is an object
-		err = json.Unmarshal([]byte(v), &m)
-		if err == nil {
-			return m, nil
-		}
+// Line is a line of a session's buffer.
+type Line struct {
+	Text string
+	// SoftWrapped is set when the line was wrapped onto the next line
+	// instead of ending with a newline.
+	SoftWrapped bool
+}
 
-		return nil, fmt.Errorf("could not unmarshal value: %w", err)
+func (s *Session) GetBuffer(r LineRange) ([]Line, error) {
+	lineRange := &api.LineRange{}
+	if r.Coords != nil {
+		lineRange.WindowedCoordRange = r.Coords.windowedCoordRange()
+	} else if r.Trailing > 0 {
+		trailing := int32(r.Trailing)
+		lineRange.TrailingLines = &trailing
+	} else {
+		screenOnly := true
+		lineRange.ScreenContentsOnly = &screenOnly
+	}
+
+	resp, err := s.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_GetBufferRequest{
+			GetBufferRequest: &api.GetBufferRequest{
+				Session:   &s.id,
+				LineRange: lineRange,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting buffer of session %q: %w", s.id, err)
+	}
+	gbr := resp.GetGetBufferResponse()
+	if status := gbr.GetStatus(); status != api.GetBufferResponse_OK {
+		return nil, fmt.Errorf("unexpected status for get buffer request: %s", status)
 	}
 
-	return m, nil
+	list := []Line{}
+	for _, lc := range gbr.GetContents() {
+		list = append(list, Line{
+			Text:        lc.GetText(),
+			SoftWrapped: lc.GetContinuation() == api.LineContents_CONTINUATION_SOFT_EOL,
+		})
+	}
+	return list, nil
 }
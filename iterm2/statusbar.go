@@ -0,0 +1,64 @@
+package iterm2
+
+import (
+	"context"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// StatusBarComponent describes a component that users can add to a
+// session's status bar. Its text is the result of a function that
+// iTerm2 calls for each session showing the component.
+type StatusBarComponent struct {
+	// RPC is the function that computes the text. iTerm2 always passes
+	// a "knobs" argument, which is added when missing. Use Defaults
+	// to get at the session, for example {"session_id": "id"}.
+	RPC RPC
+	// Identifier identifies the component uniquely, for example
+	// "com.example.featurename".
+	Identifier string
+	// ShortDescription is shown in the status bar configuration.
+	ShortDescription string
+	// DetailedDescription is shown as the component's tooltip in the
+	// status bar configuration.
+	DetailedDescription string
+	// Exemplar is sample text shown in the status bar configuration.
+	Exemplar string
+	// UpdateCadence, when set, calls the function periodically, in
+	// addition to when the variables in Defaults change.
+	UpdateCadence time.Duration
+}
+
+// RegisterStatusBarComponent registers a status bar component and
+// calls handler to compute its text until ctx is done. The handler
+// returns the text as a string, or a list of strings of decreasing
+// length of which iTerm2 shows the longest that fits.
+func (a *App) RegisterStatusBarComponent(ctx context.Context, comp StatusBarComponent, handler RPCHandler) error {
+	rpc := comp.RPC
+	hasKnobs := false
+	for _, name := range rpc.Arguments {
+		hasKnobs = hasKnobs || name == "knobs"
+	}
+	if !hasKnobs {
+		rpc.Arguments = append([]string{"knobs"}, rpc.Arguments...)
+	}
+
+	attrs := &api.RPCRegistrationRequest_StatusBarComponentAttributes{
+		UniqueIdentifier:    &comp.Identifier,
+		ShortDescription:    &comp.ShortDescription,
+		DetailedDescription: &comp.DetailedDescription,
+		Exemplar:            &comp.Exemplar,
+	}
+	if comp.UpdateCadence > 0 {
+		cadence := float32(comp.UpdateCadence.Seconds())
+		attrs.UpdateCadence = &cadence
+	}
+
+	req := rpc.registrationRequest()
+	req.Role = api.RPCRegistrationRequest_STATUS_BAR_COMPONENT.Enum()
+	req.RoleSpecificAttributes = &api.RPCRegistrationRequest_StatusBarComponentAttributes_{
+		StatusBarComponentAttributes: attrs,
+	}
+	return registerRPC(ctx, a.c, req, handler)
+}
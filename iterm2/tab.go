@@ -3,8 +3,8 @@ package iterm2
 import (
 	"fmt"
 
-	"marwan.io/iterm2/api"
-	"marwan.io/iterm2/client"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
 )
 
 type Tab struct {
@@ -17,23 +17,15 @@ func (t *Tab) GetTabID() string {
 	return t.id
 }
 
+// Window returns the window the tab is in.
+func (t *Tab) Window() *Window {
+	return &Window{c: t.c, id: t.windowID}
+}
+
+// SetTitle sets the tab's title.
 func (t *Tab) SetTitle(s string) error {
-	_, err := t.c.Call(&api.ClientOriginatedMessage{
-		Submessage: &api.ClientOriginatedMessage_InvokeFunctionRequest{
-			InvokeFunctionRequest: &api.InvokeFunctionRequest{
-				Invocation: str(fmt.Sprintf(`iterm2.set_title(title: "%s")`, s)),
-				Context: &api.InvokeFunctionRequest_Method_{
-					Method: &api.InvokeFunctionRequest_Method{
-						Receiver: &t.id,
-					},
-				},
-			},
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("could not call set_title: %w", err)
-	}
-	return nil
+	_, err := invokeMethod(t.c, t.id, "iterm2.set_title", map[string]any{"title": s})
+	return err
 }
 
 func (t *Tab) ListSessions() ([]*Session, error) {
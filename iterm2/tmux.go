@@ -0,0 +1,142 @@
+package iterm2
+
+import (
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// TmuxConnection is a tmux integration connection, started by running
+// tmux -CC in a session. Its tmux windows are shown as iTerm2 tabs.
+//
+// Requests to tmux are answered asynchronously by iTerm2, so they
+// must not be made inside a Transaction.
+type TmuxConnection struct {
+	c               *client.Client
+	id              string
+	owningSessionID string
+}
+
+// TmuxConnections returns all tmux integration connections.
+func (a *App) TmuxConnections() ([]*TmuxConnection, error) {
+	resp, err := tmuxRequest(a.c, &api.TmuxRequest{
+		Payload: &api.TmuxRequest_ListConnections_{
+			ListConnections: &api.TmuxRequest_ListConnections{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	list := []*TmuxConnection{}
+	for _, conn := range resp.GetListConnections().GetConnections() {
+		list = append(list, &TmuxConnection{
+			c:               a.c,
+			id:              conn.GetConnectionId(),
+			owningSessionID: conn.GetOwningSessionId(),
+		})
+	}
+	return list, nil
+}
+
+func (t *TmuxConnection) GetConnectionID() string {
+	return t.id
+}
+
+// OwningSession returns the session in which tmux -CC was run.
+func (t *TmuxConnection) OwningSession() *Session {
+	return &Session{c: t.c, id: t.owningSessionID}
+}
+
+// SendCommand runs a tmux command, such as "list-windows", and returns
+// its output.
+func (t *TmuxConnection) SendCommand(command string) (string, error) {
+	resp, err := tmuxRequest(t.c, &api.TmuxRequest{
+		Payload: &api.TmuxRequest_SendCommand_{
+			SendCommand: &api.TmuxRequest_SendCommand{
+				ConnectionId: &t.id,
+				Command:      &command,
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	sc := resp.GetSendCommand()
+	if sc.Output == nil {
+		return "", fmt.Errorf("tmux command %q failed", command)
+	}
+	return sc.GetOutput(), nil
+}
+
+// SetWindowVisible shows or hides the tmux window with the given ID,
+// such as "@1". A hidden window keeps running in tmux, but has no tab.
+func (t *TmuxConnection) SetWindowVisible(windowID string, visible bool) error {
+	_, err := tmuxRequest(t.c, &api.TmuxRequest{
+		Payload: &api.TmuxRequest_SetWindowVisible_{
+			SetWindowVisible: &api.TmuxRequest_SetWindowVisible{
+				ConnectionId: &t.id,
+				WindowId:     &windowID,
+				Visible:      &visible,
+			},
+		},
+	})
+	return err
+}
+
+// CreateWindow creates a tmux window and returns its tab. When
+// affinity is the ID of an iTerm2 window, the tab is created in that
+// window, otherwise in a new window.
+func (t *TmuxConnection) CreateWindow(affinity string) (*Tab, error) {
+	req := &api.TmuxRequest_CreateWindow{
+		ConnectionId: &t.id,
+	}
+	if affinity != "" {
+		req.Affinity = &affinity
+	}
+	resp, err := tmuxRequest(t.c, &api.TmuxRequest{
+		Payload: &api.TmuxRequest_CreateWindow_{
+			CreateWindow: req,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tabByID(t.c, resp.GetCreateWindow().GetTabId())
+}
+
+func tmuxRequest(c *client.Client, req *api.TmuxRequest) (*api.TmuxResponse, error) {
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_TmuxRequest{
+			TmuxRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error sending tmux request: %w", err)
+	}
+	tr := resp.GetTmuxResponse()
+	if status := tr.GetStatus(); status != api.TmuxResponse_OK {
+		return nil, fmt.Errorf("unexpected status for tmux request: %s", status)
+	}
+	return tr, nil
+}
+
+// tabByID returns the tab with the given ID, along with its window.
+func tabByID(c *client.Client, id string) (*Tab, error) {
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ListSessionsRequest{
+			ListSessionsRequest: &api.ListSessionsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list sessions: %w", err)
+	}
+	for _, w := range resp.GetListSessionsResponse().GetWindows() {
+		for _, t := range w.GetTabs() {
+			if t.GetTabId() == id {
+				return &Tab{c: c, id: id, windowID: w.GetWindowId()}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("tab %q not found", id)
+}
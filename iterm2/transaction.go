@@ -0,0 +1,55 @@
+package iterm2
+
+import (
+	"fmt"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+// Transaction runs fn while iTerm2's main loop is frozen, so that no
+// window, tab or session changes between the requests fn makes. All
+// requests on the app's connection are part of the transaction, so
+// fn can use tx or any object it got from it. Keep transactions short:
+// iTerm2 doesn't respond to the user meanwhile.
+//
+// That includes requests made by other goroutines while fn runs, such
+// as answers to RPCs. They see the frozen state, and requests iTerm2
+// answers asynchronously, like tmux requests, can't complete. Callers
+// that make requests concurrently should keep them out of transactions,
+// for example with a mutex.
+//
+// Transactions can't be nested; concurrent calls wait for each other.
+func (a *App) Transaction(fn func(tx *App) error) error {
+	a.txMu.Lock()
+	defer a.txMu.Unlock()
+
+	err := a.transaction(true)
+	if err != nil {
+		return err
+	}
+	err = fn(a)
+	endErr := a.transaction(false)
+	if err != nil {
+		return err
+	}
+	return endErr
+}
+
+func (a *App) transaction(begin bool) error {
+	resp, err := a.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_TransactionRequest{
+			TransactionRequest: &api.TransactionRequest{Begin: &begin},
+		},
+	})
+	what := "end"
+	if begin {
+		what = "begin"
+	}
+	if err != nil {
+		return fmt.Errorf("could not %s transaction: %w", what, err)
+	}
+	if status := resp.GetTransactionResponse().GetStatus(); status != api.TransactionResponse_OK {
+		return fmt.Errorf("unexpected status for %s transaction request: %s", what, status)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package iterm2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
+)
+
+// Value is the JSON encoded value of an iTerm2 variable.
+type Value json.RawMessage
+
+// IsNull reports whether the variable is unset.
+func (v Value) IsNull() bool {
+	return len(v) == 0 || string(v) == "null"
+}
+
+// String returns the value of a string variable. Other values are
+// returned as JSON, except null which is returned as "".
+func (v Value) String() string {
+	if v.IsNull() {
+		return ""
+	}
+	s := ""
+	if err := json.Unmarshal(v, &s); err != nil {
+		return string(v)
+	}
+	return s
+}
+
+// Int returns the value of a numeric variable. It fails for unset
+// variables.
+func (v Value) Int() (int64, error) {
+	var i int64
+	// json.Unmarshal leaves the zero value for null
+	if err := json.Unmarshal(v, &i); err != nil || v.IsNull() {
+		return 0, fmt.Errorf("variable is not an integer: %s", v)
+	}
+	return i, nil
+}
+
+// Float returns the value of a numeric variable. It fails for unset
+// variables.
+func (v Value) Float() (float64, error) {
+	var f float64
+	// json.Unmarshal leaves the zero value for null
+	if err := json.Unmarshal(v, &f); err != nil || v.IsNull() {
+		return 0, fmt.Errorf("variable is not a number: %s", v)
+	}
+	return f, nil
+}
+
+// Bool returns the value of a boolean variable. It fails for unset
+// variables.
+func (v Value) Bool() (bool, error) {
+	var b bool
+	// json.Unmarshal leaves the zero value for null
+	if err := json.Unmarshal(v, &b); err != nil || v.IsNull() {
+		return false, fmt.Errorf("variable is not a boolean: %s", v)
+	}
+	return b, nil
+}
+
+// Map returns the members of an object variable, such as "user" or
+// the result of getting "*".
+func (v Value) Map() (map[string]Value, error) {
+	var m map[string]Value
+	if err := json.Unmarshal(v, &m); err != nil || m == nil {
+		return nil, fmt.Errorf("variable is not an object: %s", v)
+	}
+	return m, nil
+}
+
+// Unmarshal decodes the value into dst, like json.Unmarshal.
+func (v Value) Unmarshal(dst any) error {
+	return json.Unmarshal(v, dst)
+}
+
+// MarshalJSON returns the value as is.
+func (v Value) MarshalJSON() ([]byte, error) {
+	if len(v) == 0 {
+		return []byte("null"), nil
+	}
+	return v, nil
+}
+
+// UnmarshalJSON stores a copy of data, so Values can be nested in
+// other JSON documents.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	*v = append((*v)[0:0], data...)
+	return nil
+}
+
+func variablesGet(c *client.Client, req *api.VariableRequest, vars []string) (map[string]Value, error) {
+	req.Get = vars
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_VariableRequest{
+			VariableRequest: req,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get variables: %w", err)
+	}
+	return decodeVariables(resp.GetVariableResponse(), vars)
+}
+
+// decodeVariables returns the values of a get variable response, keyed
+// by the requested names.
+func decodeVariables(vr *api.VariableResponse, vars []string) (map[string]Value, error) {
+	if vr.GetStatus() != api.VariableResponse_OK {
+		return nil, fmt.Errorf("unexpected get variable status: %s", vr.GetStatus())
+	}
+
+	values := vr.GetValues()
+	if len(values) != len(vars) {
+		return nil, fmt.Errorf("expected %d variable values, got %d", len(vars), len(values))
+	}
+
+	m := make(map[string]Value, len(vars))
+	for i, v := range values {
+		if !json.Valid([]byte(v)) {
+			return nil, fmt.Errorf("invalid JSON value for variable %q: %s", vars[i], v)
+		}
+		m[vars[i]] = Value(v)
+	}
+	return m, nil
+}
+
+func variablesSet(c *client.Client, req *api.VariableRequest, vars map[string]any) error {
+	for name, value := range vars {
+		if !strings.HasPrefix(name, "user.") {
+			return fmt.Errorf("cannot set variable %q: name must begin with \"user.\"", name)
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("could not marshal variable %q: %w", name, err)
+		}
+		req.Set = append(req.Set, &api.VariableRequest_Set{
+			Name:  str(name),
+			Value: str(string(b)),
+		})
+	}
+
+	resp, err := c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_VariableRequest{
+			VariableRequest: req,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not set variables: %w", err)
+	}
+	if status := resp.GetVariableResponse().GetStatus(); status != api.VariableResponse_OK {
+		return fmt.Errorf("unexpected set variable status: %s", status)
+	}
+	return nil
+}
+
+// WatchVariable streams the value of the session variable name each
+// time it changes, until ctx is done. A null Value means the variable
+// was unset.
+func (s *Session) WatchVariable(ctx context.Context, name string) (<-chan Value, error) {
+	return watchVariable(ctx, s.c, api.VariableScope_SESSION, s.id, name)
+}
+
+// WatchVariable streams the value of the tab variable name each time
+// it changes, until ctx is done.
+func (t *Tab) WatchVariable(ctx context.Context, name string) (<-chan Value, error) {
+	return watchVariable(ctx, t.c, api.VariableScope_TAB, t.id, name)
+}
+
+// WatchVariable streams the value of the window variable name each
+// time it changes, until ctx is done.
+func (w *Window) WatchVariable(ctx context.Context, name string) (<-chan Value, error) {
+	return watchVariable(ctx, w.c, api.VariableScope_WINDOW, w.id, name)
+}
+
+// WatchVariable streams the value of the app variable name each time
+// it changes, until ctx is done.
+func (a *App) WatchVariable(ctx context.Context, name string) (<-chan Value, error) {
+	return watchVariable(ctx, a.c, api.VariableScope_APP, "", name)
+}
+
+func watchVariable(ctx context.Context, c *client.Client, scope api.VariableScope, id, name string) (<-chan Value, error) {
+	monitor := &api.VariableMonitorRequest{
+		Name:  &name,
+		Scope: scope.Enum(),
+	}
+	if id != "" {
+		monitor.Identifier = &id
+	}
+	notifications, err := subscribe(ctx, c, &api.NotificationRequest{
+		NotificationType: api.NotificationType_NOTIFY_ON_VARIABLE_CHANGE.Enum(),
+		Arguments: &api.NotificationRequest_VariableMonitorRequest{
+			VariableMonitorRequest: monitor,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Value)
+	go func() {
+		defer close(ch)
+		for n := range notifications {
+			vcn := n.GetVariableChangedNotification()
+			if vcn == nil || vcn.GetScope() != scope || vcn.GetIdentifier() != id || vcn.GetName() != name {
+				continue
+			}
+			v := Value(vcn.GetJsonNewValue())
+			if !json.Valid(v) {
+				fmt.Fprintf(os.Stderr, "invalid JSON value for variable %q: %s\n", name, v)
+				continue
+			}
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
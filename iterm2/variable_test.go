@@ -0,0 +1,219 @@
+package iterm2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+)
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Value
+		str   string
+		i     int64
+		iErr  bool
+		f     float64
+		fErr  bool
+		b     bool
+		bErr  bool
+		keys  []string
+		mErr  bool
+	}{
+		{
+			name:  "string",
+			value: Value(`"abc"`),
+			str:   "abc",
+			iErr:  true,
+			fErr:  true,
+			bErr:  true,
+			mErr:  true,
+		},
+		{
+			name:  "integer",
+			value: Value(`42`),
+			str:   "42",
+			i:     42,
+			f:     42,
+			bErr:  true,
+			mErr:  true,
+		},
+		{
+			name:  "float",
+			value: Value(`1.5`),
+			str:   "1.5",
+			f:     1.5,
+			iErr:  true,
+			bErr:  true,
+			mErr:  true,
+		},
+		{
+			name:  "boolean",
+			value: Value(`true`),
+			str:   "true",
+			b:     true,
+			iErr:  true,
+			fErr:  true,
+			mErr:  true,
+		},
+		{
+			name:  "null",
+			value: Value(`null`),
+			str:   "",
+			iErr:  true,
+			fErr:  true,
+			bErr:  true,
+			mErr:  true,
+		},
+		{
+			name:  "empty",
+			value: nil,
+			str:   "",
+			iErr:  true,
+			fErr:  true,
+			bErr:  true,
+			mErr:  true,
+		},
+		{
+			name:  "object",
+			value: Value(`{"a":{"b":1},"c":"d"}`),
+			str:   `{"a":{"b":1},"c":"d"}`,
+			keys:  []string{"a", "c"},
+			iErr:  true,
+			fErr:  true,
+			bErr:  true,
+		},
+		{
+			name:  "array",
+			value: Value(`[1,2]`),
+			str:   "[1,2]",
+			iErr:  true,
+			fErr:  true,
+			bErr:  true,
+			mErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.value.String(); got != tt.str {
+				t.Errorf("String() = %q, want %q", got, tt.str)
+			}
+
+			i, err := tt.value.Int()
+			if (err != nil) != tt.iErr || i != tt.i {
+				t.Errorf("Int() = %v, %v, want %v, error %v", i, err, tt.i, tt.iErr)
+			}
+
+			f, err := tt.value.Float()
+			if (err != nil) != tt.fErr || f != tt.f {
+				t.Errorf("Float() = %v, %v, want %v, error %v", f, err, tt.f, tt.fErr)
+			}
+
+			b, err := tt.value.Bool()
+			if (err != nil) != tt.bErr || b != tt.b {
+				t.Errorf("Bool() = %v, %v, want %v, error %v", b, err, tt.b, tt.bErr)
+			}
+
+			m, err := tt.value.Map()
+			if (err != nil) != tt.mErr {
+				t.Fatalf("Map() error = %v, want error %v", err, tt.mErr)
+			}
+			keys := []string{}
+			for k := range m {
+				keys = append(keys, k)
+			}
+			if len(keys) != len(tt.keys) {
+				t.Errorf("Map() keys = %v, want %v", keys, tt.keys)
+			}
+			for _, k := range tt.keys {
+				if _, ok := m[k]; !ok {
+					t.Errorf("Map() keys = %v, want %v", keys, tt.keys)
+				}
+			}
+		})
+	}
+}
+
+func TestValueNestedMap(t *testing.T) {
+	data := []byte(`{"a":{"b":1,"c":[true]},"d":"e"}`)
+	m, err := Value(data).Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the members must not share memory with the document
+	copy(data, "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx")
+
+	if got := m["d"].String(); got != "e" {
+		t.Errorf(`m["d"].String() = %q, want "e"`, got)
+	}
+	nested, err := m["a"].Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := nested["b"].Int(); err != nil || n != 1 {
+		t.Errorf(`nested["b"].Int() = %v, %v, want 1`, n, err)
+	}
+	if got := nested["c"].String(); got != "[true]" {
+		t.Errorf(`nested["c"].String() = %q, want "[true]"`, got)
+	}
+	if _, err := nested["missing"].Int(); err == nil {
+		t.Error(`nested["missing"].Int() succeeded, want error`)
+	}
+}
+
+func TestDecodeVariables(t *testing.T) {
+	ok := api.VariableResponse_OK.Enum()
+	tests := []struct {
+		name    string
+		resp    *api.VariableResponse
+		vars    []string
+		want    map[string]Value
+		wantErr bool
+	}{
+		{
+			name: "values",
+			resp: &api.VariableResponse{Status: ok, Values: []string{`"zsh"`, `null`}},
+			vars: []string{"jobName", "user.missing"},
+			want: map[string]Value{"jobName": Value(`"zsh"`), "user.missing": Value(`null`)},
+		},
+		{
+			name:    "too few values",
+			resp:    &api.VariableResponse{Status: ok, Values: []string{`"zsh"`}},
+			vars:    []string{"jobName", "path"},
+			wantErr: true,
+		},
+		{
+			name:    "too many values",
+			resp:    &api.VariableResponse{Status: ok, Values: []string{`"zsh"`, `"/"`}},
+			vars:    []string{"jobName"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			resp:    &api.VariableResponse{Status: ok, Values: []string{`{"a":`}},
+			vars:    []string{"user"},
+			wantErr: true,
+		},
+		{
+			name:    "status",
+			resp:    &api.VariableResponse{Status: api.VariableResponse_SESSION_NOT_FOUND.Enum()},
+			vars:    []string{"jobName"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeVariables(tt.resp, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeVariables() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
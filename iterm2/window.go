@@ -1,11 +1,12 @@
 package iterm2
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 
-	"marwan.io/iterm2/api"
-	"marwan.io/iterm2/client"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/api"
+	"github.com/LeonB/iterm2-toggle-session/iterm2/client"
 )
 
 type Window struct {
@@ -15,15 +16,48 @@ type Window struct {
 }
 
 func (w *Window) GetWindowID() string {
-    return w.id
+	return w.id
+}
+
+// CreateTabOptions for customizing the session of a new tab or
+// window.
+type CreateTabOptions struct {
+	// ProfileName is the profile to use. Leave empty for the default
+	// profile.
+	ProfileName string
+	// Command replaces the profile's command for this session only.
+	Command string
+}
+
+func (o CreateTabOptions) createTabRequest() *api.CreateTabRequest {
+	req := &api.CreateTabRequest{}
+	if o.ProfileName != "" {
+		req.ProfileName = str(o.ProfileName)
+	}
+	if o.Command != "" {
+		req.CustomProfileProperties = []*api.ProfileProperty{
+			{Key: str("Custom Command"), JsonValue: str(`"Yes"`)},
+			{Key: str("Command"), JsonValue: str(jsonString(o.Command))},
+		}
+	}
+	return req
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
 }
 
 func (w *Window) CreateTab() (*Tab, error) {
+	return w.CreateTabWithOptions(CreateTabOptions{})
+}
+
+func (w *Window) CreateTabWithOptions(opts CreateTabOptions) (*Tab, error) {
+	req := opts.createTabRequest()
+	req.WindowId = str(w.id)
 	resp, err := w.c.Call(&api.ClientOriginatedMessage{
 		Submessage: &api.ClientOriginatedMessage_CreateTabRequest{
-			CreateTabRequest: &api.CreateTabRequest{
-				WindowId: str(w.id),
-			},
+			CreateTabRequest: req,
 		},
 	})
 	if err != nil {
@@ -65,32 +99,54 @@ func (w *Window) ListTabs() ([]*Tab, error) {
 	return list, nil
 }
 
-func (w *Window) SetTitle(s string) error {
-	_, err := w.c.Call(&api.ClientOriginatedMessage{
-		Submessage: &api.ClientOriginatedMessage_InvokeFunctionRequest{
-			InvokeFunctionRequest: &api.InvokeFunctionRequest{
-				Invocation: str(fmt.Sprintf(`iterm2.set_title(title: "%s")`, s)),
-				Context: &api.InvokeFunctionRequest_Method_{
-					Method: &api.InvokeFunctionRequest_Method{
-						Receiver: &w.id,
-					},
+// ReorderTabs puts the window's tabs in the given order. All tabs of
+// the window must be given; tabs of other windows are moved into it.
+func (w *Window) ReorderTabs(tabs []*Tab) error {
+	ids := []string{}
+	for _, t := range tabs {
+		ids = append(ids, t.id)
+	}
+	resp, err := w.c.Call(&api.ClientOriginatedMessage{
+		Submessage: &api.ClientOriginatedMessage_ReorderTabsRequest{
+			ReorderTabsRequest: &api.ReorderTabsRequest{
+				Assignments: []*api.ReorderTabsRequest_Assignment{
+					{WindowId: &w.id, TabIds: ids},
 				},
 			},
 		},
 	})
+	if err != nil {
+		return fmt.Errorf("error reordering tabs of window %q: %w", w.id, err)
+	}
+	if status := resp.GetReorderTabsResponse().GetStatus(); status != api.ReorderTabsResponse_OK {
+		return fmt.Errorf("unexpected status for reorder tabs request: %s", status)
+	}
+	return nil
+}
+
+// SetTitle sets the window's title.
+func (w *Window) SetTitle(s string) error {
+	_, err := invokeMethod(w.c, w.id, "iterm2.set_title", map[string]any{"title": s})
 	return err
 }
 
 func (w *Window) Activate() error {
-	orderWindowFront := true
+	return w.ActivateWithOptions(ActivateOptions{
+		OrderWindowFront: true,
+	})
+}
+
+func (w *Window) ActivateWithOptions(opts ActivateOptions) error {
+	req := activateRequest(opts)
+	req.Identifier = &api.ActivateRequest_WindowId{
+		WindowId: w.id,
+	}
+	// select_tab is only valid for tab and session identifiers
+	req.SelectTab = nil
+
 	resp, err := w.c.Call(&api.ClientOriginatedMessage{
 		Submessage: &api.ClientOriginatedMessage_ActivateRequest{
-			ActivateRequest: &api.ActivateRequest{
-				Identifier: &api.ActivateRequest_WindowId{
-					WindowId: w.id,
-				},
-				OrderWindowFront: &orderWindowFront,
-			},
+			ActivateRequest: req,
 		},
 	})
 	if err != nil {
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// modifiers are the modifier keys a key binding can require, by name.
+var modifiers = map[string]iterm2.Modifier{
+	"control": iterm2.Control,
+	"option":  iterm2.Option,
+	"command": iterm2.Command,
+	"shift":   iterm2.Shift,
+}
+
+// keyBinding toggles a target when a key is pressed while iTerm2 is
+// the frontmost app, for example:
+//
+//	{"modifiers": ["control", "option"], "characters": "v", "toggle": "vim"}
+type keyBinding struct {
+	// Modifiers are the modifier keys that must be held: "control",
+	// "option", "command" or "shift". Other modifiers must not be.
+	Modifiers []string `json:"modifiers"`
+	// Characters is the key, as typed without modifiers.
+	Characters string `json:"characters"`
+	// KeyCode is the virtual key code, for keys that don't type
+	// characters. It is used instead of Characters.
+	KeyCode *int `json:"key_code"`
+	// Toggle is the target to toggle.
+	Toggle string `json:"toggle"`
+}
+
+// pattern returns the keystrokes the binding matches.
+func (b keyBinding) pattern() (iterm2.KeystrokePattern, error) {
+	p := iterm2.KeystrokePattern{}
+	required := map[string]bool{}
+	for _, name := range b.Modifiers {
+		m, ok := modifiers[strings.ToLower(name)]
+		if !ok {
+			return p, fmt.Errorf("unknown modifier %q", name)
+		}
+		required[strings.ToLower(name)] = true
+		p.RequiredModifiers = append(p.RequiredModifiers, m)
+	}
+	for name, m := range modifiers {
+		if !required[name] {
+			p.ForbiddenModifiers = append(p.ForbiddenModifiers, m)
+		}
+	}
+
+	switch {
+	case b.KeyCode != nil:
+		p.KeyCodes = []int{*b.KeyCode}
+	case b.Characters != "":
+		p.CharactersIgnoringModifiers = []string{b.Characters}
+	default:
+		return p, fmt.Errorf("characters or key_code is required")
+	}
+	if b.Toggle == "" {
+		return p, fmt.Errorf("toggle is required")
+	}
+	return p, nil
+}
+
+// watchKeys toggles the targets of the configured key bindings, until
+// ctx is done. The bound keys are kept from iTerm2's own handling.
+func (t *toggler) watchKeys(ctx context.Context) error {
+	if len(t.config.Keys) == 0 {
+		return nil
+	}
+
+	patterns := []iterm2.KeystrokePattern{}
+	for _, b := range t.config.Keys {
+		// the config was validated when loaded
+		p, _ := b.pattern()
+		patterns = append(patterns, p)
+	}
+
+	keystrokes, err := t.app.WatchKeystrokes(ctx, patterns...)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for k := range keystrokes {
+			for i, p := range patterns {
+				if !p.Matches(k) {
+					continue
+				}
+				arg := t.config.Keys[i].Toggle
+				log.Printf("key %q toggles %s", k.CharactersIgnoringModifiers, arg)
+				err := t.handleArg(arg)
+				if err != nil {
+					log.Println("could not toggle", arg, err)
+				}
+				break
+			}
+		}
+		log.Println("stopped watching keys")
+	}()
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andybrewer/mack"
+)
+
+// iTerm2's process name, as known to System Events.
+const itermProcessName = "iTerm2"
+
+// iTerm2's bundle identifier.
+const itermBundleID = "com.googlecode.iterm2"
+
+// frontmostApp returns the bundle identifier of the frontmost macOS
+// application. Unlike its process name, it always identifies the
+// application.
+func frontmostApp() (string, error) {
+	id, err := mack.Tell("System Events", "bundle identifier of first application process whose frontmost is true")
+	if err != nil {
+		return "", fmt.Errorf("could not get frontmost app: %w", err)
+	}
+	return strings.TrimSpace(id), nil
+}
+
+// activateApp brings the macOS application with the given bundle
+// identifier to the front.
+func activateApp(bundleID string) error {
+	_, err := mack.Tell("System Events", fmt.Sprintf("tell application id %q to activate", bundleID))
+	if err != nil {
+		return fmt.Errorf("could not activate app %q: %w", bundleID, err)
+	}
+	return nil
+}
+
+// hideIterm hides iTerm2. macOS then activates the app that was
+// frontmost before it.
+func hideIterm() error {
+	_, err := mack.Tell("System Events", fmt.Sprintf("set visible of application process %q to false", itermProcessName))
+	if err != nil {
+		return fmt.Errorf("could not hide iTerm2: %w", err)
+	}
+	return nil
+}
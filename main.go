@@ -30,9 +30,10 @@ func main() {
 
 func run(ctx context.Context) (int, error) {
 	ctx, cancel := context.WithCancel(ctx)
+	args := os.Args[1:]
 	arg := ""
-	if len(os.Args) > 1 {
-		arg = os.Args[1]
+	if len(args) > 0 {
+		arg = args[0]
 	}
 
 	// handle interrupts
@@ -46,6 +47,14 @@ func run(ctx context.Context) (int, error) {
 	}()
 
 	if fifoExists(pipeFile) {
+		if isCommand(args) {
+			output, err := sendRequestToPipe(pipeFile, args, ctx)
+			fmt.Print(output)
+			if err != nil {
+				return 2, err
+			}
+			return 0, nil
+		}
 		if arg != "" {
 			err := sendArgToPipe(pipeFile, arg, ctx)
 			if err != nil {
@@ -55,6 +64,33 @@ func run(ctx context.Context) (int, error) {
 		return 0, nil
 	}
 
+	// no daemon is running, so run the command on a connection of our own
+	if isCommand(args) {
+		cfg, err := loadConfigFile()
+		if err != nil {
+			return 5, err
+		}
+		app, err := createApp()
+		if err != nil && args[0] == "doctor" {
+			// report the failed connection along with the other checks
+			output, err := (&toggler{config: cfg}).doctor(err)
+			fmt.Print(output)
+			return 6, err
+		}
+		if err != nil {
+			return 5, err
+		}
+		t := &toggler{app: app, config: cfg}
+		defer t.app.Close()
+
+		output, err := t.runCommand(args)
+		fmt.Print(output)
+		if err != nil {
+			return 6, err
+		}
+		return 0, nil
+	}
+
 	err := createPipe(pipeFile)
 	if err != nil {
 		return 3, err
@@ -77,15 +113,39 @@ func run(ctx context.Context) (int, error) {
 		log.Println("done cleaning up named pipe")
 	}()
 
-	// create the app
-	app, err := createApp()
+	t, err := newToggler()
 	if err != nil {
 		return 5, err
 	}
-	defer app.Close()
+	defer t.app.Close()
+
+	err = t.watchFocus(ctx)
+	if err != nil {
+		log.Println("could not watch focus:", err)
+	}
+
+	err = t.registerRPC(ctx)
+	if err != nil {
+		log.Println("could not register toggle function:", err)
+	}
+
+	err = t.registerStatusBar(ctx)
+	if err != nil {
+		log.Println("could not register status bar component:", err)
+	}
+
+	err = t.watchKeys(ctx)
+	if err != nil {
+		log.Println("could not watch keys:", err)
+	}
+
+	err = t.watchTags(ctx)
+	if err != nil {
+		log.Println("could not watch tags:", err)
+	}
 
 	if arg != "" {
-		err = handleArg(app, arg)
+		err = t.handleArg(arg)
 		if err != nil {
 			return 6, err
 		}
@@ -95,8 +155,15 @@ func run(ctx context.Context) (int, error) {
 	argErrChan := make(chan error)
 	go func() {
 		for arg := range inputChan {
+			if isRequest(arg) {
+				log.Println("received request", arg)
+				handleRequest(t, arg)
+				log.Println("handleRequest done")
+				continue
+			}
+
 			log.Println("received arg", arg)
-			err := handleArg(app, arg)
+			err := t.handleArg(arg)
 			if err != nil {
 				argErrChan <- err
 			}
@@ -123,153 +190,30 @@ func createApp() (*iterm2.App, error) {
 	return iterm2.NewApp("iterm2-toggle")
 }
 
-func handleArg(app *iterm2.App, arg string) error {
-	notifications, err := app.Focus()
-	if err != nil {
-		return err
-	}
-
-	var (
-		currentWindow  *iterm2.Window
-		activeTabs     []string
-		activeSessions []string
-		currentTab     *iterm2.Tab
-		currentSession string
-	)
-
-	for _, n := range notifications {
-		if window := n.GetWindow(); window != nil {
-			currentWindow = window
-		}
-
-		if tabID := n.GetSelectedTab(); tabID != "" {
-			activeTabs = append(activeTabs, tabID)
-		}
-
-		if sessionID := n.GetSession(); sessionID != "" {
-			activeSessions = append(activeSessions, sessionID)
-		}
-	}
-
-	// from the current window, get the active tab
-	windowTabs, err := currentWindow.ListTabs()
-	if err != nil {
-		return err
-	}
-
-	for _, wt := range windowTabs {
-		for _, at := range activeTabs {
-			if wt.GetTabID() == at {
-				currentTab = wt
-			}
-		}
-	}
-
-	// from the current tab, get the active session
-	tabSessions, err := currentTab.ListSessions()
-	if err != nil {
-		return err
-	}
-
-	for _, ts := range tabSessions {
-		for _, as := range activeSessions {
-			if ts.GetSessionID() == as {
-				currentSession = ts.GetSessionID()
-			}
-		}
-	}
-
-	// newest events are last
-	// loop notifications in reverse
-	for i := len(notifications) - 1; i >= 0; i-- {
-		n := notifications[i]
-		if currentSession == "" {
-			if id := n.GetSession(); id != "" {
-				currentSession = id
-				break
-			}
-		}
-	}
-
-	windows, err := app.ListWindows()
+// loadConfigFile loads the config from its default location.
+func loadConfigFile() (*config, error) {
+	cfgPath, err := configPath()
 	if err != nil {
-		return err
-	}
-
-	// get a list of sessions
-	sessions := []*iterm2.Session{}
-	for _, w := range windows {
-		tabs, err := w.ListTabs()
-		if err != nil {
-			return err
-		}
-
-		for _, t := range tabs {
-			ss, err := t.ListSessions()
-			if err != nil {
-				return err
-			}
-
-			for _, s := range ss {
-				// get the process title of this session
-				vars, err := s.VariablesGet([]string{"processTitle"})
-				if err != nil {
-					return err
-				}
-
-				title := vars["processTitle"]
-				if !strings.Contains(title, arg) {
-					log.Printf("skipping session '%s', does not match arg '%s'", title, arg)
-					continue
-				}
-
-				log.Printf("appending session, matches %s", title)
-				sessions = append(sessions, s)
-			}
-		}
-	}
-
-	if len(sessions) == 0 {
-		log.Println("no matching sessions found")
-		return nil
-	}
-
-	// get index of current session
-	currentIndex := -1
-	for i, s := range sessions {
-		if s.GetSessionID() == currentSession {
-			currentIndex = i
-			break
-		}
-	}
-
-	log.Println("current index", currentIndex)
-
-	// if currentIndex is the last session, pick the first one
-	next := sessions[0]
-
-	// if the currentIndex is not the last session, pick the next one in the
-	// list
-	if currentIndex != len(sessions)-1 {
-		next = sessions[currentIndex+1]
+		return nil, err
 	}
+	return loadConfig(cfgPath)
+}
 
-	log.Println("next", next.GetSessionID())
-
-	// activate the session
-	log.Println("activating session", next.GetSessionID())
-	err = next.Activate(true, true)
+// newToggler loads the config and connects to iTerm2. The caller must
+// close the toggler's app.
+func newToggler() (*toggler, error) {
+	cfg, err := loadConfigFile()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	log.Println("activating app")
-	err = app.Activate(false, true)
+	// create the app
+	app, err := createApp()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &toggler{app: app, config: cfg}, nil
 }
 
 func fifoExists(pipeFile string) bool {
@@ -335,15 +279,17 @@ func readFromPipe(_ context.Context, file *os.File) (<-chan string, <-chan error
 		reader := bufio.NewReader(file)
 		for {
 			log.Println("started reading from pipe")
-			line, _, err := reader.ReadLine()
-			log.Println("read line", string(line))
+			// requests can be longer than the reader's buffer, so don't use ReadLine
+			line, err := reader.ReadString('\n')
+			line = strings.TrimSuffix(line, "\n")
+			log.Println("read line", line)
 			if err != nil {
 				log.Println("error reading from pipe", err)
 				errChan <- err
 			}
 
-			log.Println("sending line to input chan", string(line))
-			inputChan <- string(line)
+			log.Println("sending line to input chan", line)
+			inputChan <- line
 		}
 	}()
 
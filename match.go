@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// matcher selects the sessions that belong to a target. All rules
+// that are set must match.
+type matcher struct {
+	// ProcessTitle matches sessions whose process title contains it.
+	// If no rule is set, it defaults to the toggle argument.
+	ProcessTitle string `json:"process_title"`
+	// Screen is a regular expression that is matched against the
+	// session's buffer.
+	Screen string `json:"screen"`
+	// ScreenLines is the number of trailing lines, including
+	// scrollback, that Screen is matched against. When 0 it is matched
+	// against the visible screen.
+	ScreenLines int `json:"screen_lines"`
+	// Idle matches sessions whose shell is waiting at a prompt rather
+	// than running a command. Requires shell integration.
+	Idle bool `json:"idle"`
+	// Directory matches sessions whose current directory is this
+	// directory. A leading "~" is expanded to the home directory.
+	Directory string `json:"directory"`
+	// Profile matches sessions using the profile with this name.
+	Profile string `json:"profile"`
+	// Tag matches sessions that were tagged with this tag, regardless
+	// of what they run, see watchTags.
+	Tag string `json:"tag"`
+	// TmuxSession matches sessions in tmux integration windows of the
+	// tmux session with this name.
+	TmuxSession string `json:"tmux_session"`
+	// TmuxWindow matches sessions in tmux integration windows with
+	// this name. Hidden windows are shown when toggled.
+	TmuxWindow string `json:"tmux_window"`
+
+	screen *regexp.Regexp
+	// orTag is a fallback for the process title rule: sessions tagged
+	// with it match even if their process title doesn't. It is set for
+	// targets without rules, so they find tagged sessions by name.
+	//
+	// Tags are set by whatever a session prints, see watchTags, so
+	// anything shown in it, such as a file printed with cat or output
+	// of a remote host, can tag it. A tag therefore never overrides
+	// other rules: it only stands in for the process title of
+	// unconfigured targets, and configured targets only look at tags
+	// when they have a tag rule.
+	orTag string
+	// tmux holds the tmux windows, when the tmux rules are set.
+	// findSessions lists them if they weren't listed beforehand.
+	tmux tmuxWindows
+}
+
+// compile validates the rules and prepares them for matching.
+func (m *matcher) compile() error {
+	if m.ScreenLines < 0 {
+		return fmt.Errorf("screen_lines must not be negative")
+	}
+	if m.Directory != "" {
+		dir, err := expandHome(m.Directory)
+		if err != nil {
+			return err
+		}
+		m.Directory = filepath.Clean(dir)
+	}
+	if m.Screen == "" {
+		return nil
+	}
+	re, err := regexp.Compile(m.Screen)
+	if err != nil {
+		return fmt.Errorf("invalid screen expression: %w", err)
+	}
+	m.screen = re
+	return nil
+}
+
+func expandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, p[1:]), nil
+}
+
+// empty reports whether no rule is set.
+func (m matcher) empty() bool {
+	return m.ProcessTitle == "" && m.Screen == "" && !m.Idle && m.Directory == "" && m.Profile == "" && m.Tag == "" && !m.usesTmux()
+}
+
+// usesTmux reports whether a tmux rule is set.
+func (m matcher) usesTmux() bool {
+	return m.TmuxSession != "" || m.TmuxWindow != ""
+}
+
+// matchTmuxID reports whether the tmux windows with the given ID match
+// the tmux rules. Windows of different tmux servers can share an ID,
+// and a session can't tell which one it's in, so it matches only when
+// all of them do.
+func (m matcher) matchTmuxID(id string) bool {
+	windows := m.tmux.withID(id)
+	for _, w := range windows {
+		if !m.matchTmux(w) {
+			return false
+		}
+	}
+	return len(windows) > 0
+}
+
+// matchTmux reports whether w matches the tmux rules.
+func (m matcher) matchTmux(w tmuxWindow) bool {
+	return (m.TmuxSession == "" || w.session == m.TmuxSession) &&
+		(m.TmuxWindow == "" || w.name == m.TmuxWindow)
+}
+
+// match reports whether s matches, and describes the session for
+// logging.
+func (m matcher) match(s *iterm2.Session) (bool, string, error) {
+	vars, err := s.VariablesGet([]string{"processTitle", "path", tagVariable})
+	if err != nil {
+		return false, "", err
+	}
+	title := vars["processTitle"].String()
+
+	if !m.matchVariables(vars) {
+		return false, title, nil
+	}
+
+	if m.usesTmux() {
+		id, err := tmuxWindowOf(s)
+		if err != nil {
+			return false, title, err
+		}
+		if id == "" || !m.matchTmuxID(id) {
+			return false, title, nil
+		}
+	}
+
+	if m.Profile != "" {
+		p, err := s.Profile("Name")
+		if err != nil {
+			return false, title, err
+		}
+		if p.Name() != m.Profile {
+			return false, title, nil
+		}
+	}
+
+	if m.Idle {
+		p, err := s.CurrentPrompt()
+		if err != nil {
+			return false, title, err
+		}
+		// without shell integration it is unknown whether the shell is idle
+		if p == nil || p.State != iterm2.PromptEditing {
+			return false, title, nil
+		}
+	}
+
+	if m.screen != nil {
+		lines, err := s.GetBuffer(iterm2.LineRange{Trailing: m.ScreenLines})
+		if err != nil {
+			return false, title, err
+		}
+		if !m.screen.MatchString(joinLines(lines)) {
+			return false, title, nil
+		}
+	}
+
+	return true, title, nil
+}
+
+// matchVariables applies the rules that only need the session's
+// process title, path and tag variables.
+func (m matcher) matchVariables(vars map[string]iterm2.Value) bool {
+	tag := vars[tagVariable].String()
+
+	if !strings.Contains(vars["processTitle"].String(), m.ProcessTitle) && (m.orTag == "" || tag != m.orTag) {
+		return false
+	}
+
+	if m.Tag != "" && tag != m.Tag {
+		return false
+	}
+
+	if m.Directory != "" {
+		path := vars["path"].String()
+		if path == "" || filepath.Clean(path) != m.Directory {
+			return false
+		}
+	}
+	return true
+}
+
+// joinLines joins buffer lines into text, joining soft wrapped lines
+// without a newline so expressions can match across them.
+func joinLines(lines []iterm2.Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.Text)
+		if !l.SoftWrapped {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// findSessions returns all sessions that match m, in window, tab and
+// pane order, along with the window of each session, keyed by session
+// ID.
+func findSessions(app *iterm2.App, m matcher) ([]*iterm2.Session, map[string]*iterm2.Window, error) {
+	if m.usesTmux() && m.tmux == nil {
+		tmux, err := listTmuxWindows(app)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.tmux = tmux
+	}
+
+	windows, err := app.ListWindows()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessions := []*iterm2.Session{}
+	windowOf := map[string]*iterm2.Window{}
+	for _, w := range windows {
+		tabs, err := w.ListTabs()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, t := range tabs {
+			ss, err := t.ListSessions()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			for _, s := range ss {
+				ok, title, err := m.match(s)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				if !ok {
+					log.Printf("skipping session '%s', does not match", title)
+					continue
+				}
+
+				log.Printf("appending session, matches %s", title)
+				sessions = append(sessions, s)
+				windowOf[s.GetSessionID()] = w
+			}
+		}
+	}
+	return sessions, windowOf, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+func TestMatchVariables(t *testing.T) {
+	vars := func(title, path, tag string) map[string]iterm2.Value {
+		v := map[string]iterm2.Value{
+			"processTitle": iterm2.Value(`"` + title + `"`),
+			"path":         iterm2.Value(`"` + path + `"`),
+		}
+		if tag != "" {
+			v[tagVariable] = iterm2.Value(`"` + tag + `"`)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		m    matcher
+		vars map[string]iterm2.Value
+		want bool
+	}{
+		{"title", matcher{ProcessTitle: "vim"}, vars("nvim", "/", ""), true},
+		{"other title", matcher{ProcessTitle: "vim"}, vars("zsh", "/", ""), false},
+		{"tag", matcher{Tag: "notes"}, vars("zsh", "/", "notes"), true},
+		{"other tag", matcher{Tag: "notes"}, vars("zsh", "/", "todo"), false},
+		{"untagged", matcher{Tag: "notes"}, vars("zsh", "/", ""), false},
+		{"tag and title", matcher{ProcessTitle: "vim", Tag: "notes"}, vars("zsh", "/", "notes"), false},
+		{"or tag with title", matcher{ProcessTitle: "vim", orTag: "vim"}, vars("vim", "/", ""), true},
+		{"or tag instead of title", matcher{ProcessTitle: "vim", orTag: "vim"}, vars("zsh", "/", "vim"), true},
+		{"or tag other tag", matcher{ProcessTitle: "vim", orTag: "vim"}, vars("zsh", "/", "notes"), false},
+		{"or tag keeps other rules", matcher{ProcessTitle: "vim", orTag: "vim", Directory: "/src"}, vars("zsh", "/tmp", "vim"), false},
+		{"directory", matcher{Directory: "/src"}, vars("zsh", "/src/", ""), true},
+		{"other directory", matcher{Directory: "/src"}, vars("zsh", "/tmp", ""), false},
+		{"unknown directory", matcher{Directory: "/src"}, vars("zsh", "", ""), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.matchVariables(tt.vars); got != tt.want {
+				t.Errorf("matchVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		m    matcher
+		want bool
+	}{
+		{"no rules", matcher{}, true},
+		{"or tag", matcher{orTag: "vim"}, true},
+		{"process title", matcher{ProcessTitle: "vim"}, false},
+		{"screen", matcher{Screen: "foo"}, false},
+		{"idle", matcher{Idle: true}, false},
+		{"directory", matcher{Directory: "/src"}, false},
+		{"profile", matcher{Profile: "Default"}, false},
+		{"tag", matcher{Tag: "notes"}, false},
+		{"tmux session", matcher{TmuxSession: "main"}, false},
+		{"tmux window", matcher{TmuxWindow: "logs"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.empty(); got != tt.want {
+				t.Errorf("empty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
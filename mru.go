@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// mru keeps session IDs in most recently used order.
+type mru struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// touch marks a session as the most recently used one.
+func (m *mru) touch(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, other := range m.ids {
+		if other == id {
+			m.ids = append(m.ids[:i], m.ids[i+1:]...)
+			break
+		}
+	}
+	m.ids = append([]string{id}, m.ids...)
+}
+
+// remove forgets a session, once it has ended.
+func (m *mru) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, other := range m.ids {
+		if other == id {
+			m.ids = append(m.ids[:i], m.ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// pick returns the most recently used of sessions. Sessions that were
+// never used rank below the ones that were, in their given order.
+func (m *mru) pick(sessions []*iterm2.Session) *iterm2.Session {
+	if len(sessions) == 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range m.ids {
+		for _, s := range sessions {
+			if s.GetSessionID() == id {
+				return s
+			}
+		}
+	}
+	return sessions[0]
+}
+
+// watchFocus keeps the toggler's MRU list up to date with the active
+// session, until ctx is done. Sessions are dropped from it when they
+// end, so it doesn't grow for as long as the daemon runs.
+func (t *toggler) watchFocus(ctx context.Context) error {
+	focus, err := t.app.CurrentFocus()
+	if err != nil {
+		return err
+	}
+	if focus.Session != nil {
+		t.recent.touch(focus.Session.GetSessionID())
+	}
+
+	notifications, err := t.app.WatchFocus(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for n := range notifications {
+			// a session became active in its tab; that may not be the
+			// key window, but it's the best signal available
+			if id := n.GetSession(); id != "" {
+				t.recent.touch(id)
+			}
+		}
+		log.Println("stopped watching focus")
+	}()
+
+	terminations, err := t.app.WatchTerminations(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for id := range terminations {
+			t.recent.remove(id)
+		}
+		log.Println("stopped watching terminations")
+	}()
+	return nil
+}
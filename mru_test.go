@@ -0,0 +1,36 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMRU(t *testing.T) {
+	tests := []struct {
+		name   string
+		touch  []string
+		remove []string
+		want   []string
+	}{
+		{name: "empty", want: nil},
+		{name: "most recent first", touch: []string{"a", "b", "c"}, want: []string{"c", "b", "a"}},
+		{name: "touch again moves to front", touch: []string{"a", "b", "a"}, want: []string{"a", "b"}},
+		{name: "remove", touch: []string{"a", "b", "c"}, remove: []string{"b"}, want: []string{"c", "a"}},
+		{name: "remove unknown", touch: []string{"a"}, remove: []string{"x"}, want: []string{"a"}},
+		{name: "remove all", touch: []string{"a", "b"}, remove: []string{"a", "b"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m mru
+			for _, id := range tt.touch {
+				m.touch(id)
+			}
+			for _, id := range tt.remove {
+				m.remove(id)
+			}
+			if !slices.Equal(m.ids, tt.want) {
+				t.Errorf("ids = %v, want %v", m.ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// How long to wait for the daemon to run a command.
+const replyTimeout = 30 * time.Second
+
+// request asks the daemon to run a command. It is sent over the named
+// pipe as a single line of JSON, which tells it apart from a plain
+// toggle argument. The daemon writes a response to the Reply pipe.
+type request struct {
+	Args  []string `json:"args"`
+	Reply string   `json:"reply"`
+}
+
+// response is the result of a request.
+type response struct {
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// replyPrefix and replySuffix make up the name of a reply pipe, around
+// the process ID of the sender.
+const (
+	replyPrefix = "iterm2-toggle-"
+	replySuffix = ".reply.fifo"
+)
+
+func isRequest(line string) bool {
+	return strings.HasPrefix(line, "{")
+}
+
+// sendRequestToPipe asks the daemon to run a command and waits for
+// its output.
+func sendRequestToPipe(pipeFile string, args []string, ctx context.Context) (string, error) {
+	reply := path.Join(os.TempDir(), fmt.Sprintf("%s%d%s", replyPrefix, os.Getpid(), replySuffix))
+	err := createPipe(reply)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(reply)
+
+	// use O_RDWR so the daemon can open the reply pipe without blocking
+	f, err := os.OpenFile(reply, os.O_RDWR, os.ModeNamedPipe)
+	if err != nil {
+		return "", fmt.Errorf("Open named pipe file (%s) error: %s", reply, err)
+	}
+	defer f.Close()
+
+	b, err := json.Marshal(request{Args: args, Reply: reply})
+	if err != nil {
+		return "", err
+	}
+	err = sendArgToPipe(pipeFile, string(b), ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancelFunc := context.WithTimeout(ctx, replyTimeout)
+	defer cancelFunc()
+
+	respChan := make(chan response, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		var resp response
+		err := json.NewDecoder(f).Decode(&resp)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		respChan <- resp
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("Read response from pipe (%s) error: %s", reply, ctx.Err())
+	case err := <-errChan:
+		return "", fmt.Errorf("Read response from pipe (%s) error: %s", reply, err)
+	case resp := <-respChan:
+		if resp.Error != "" {
+			return resp.Output, errors.New(resp.Error)
+		}
+		return resp.Output, nil
+	}
+}
+
+// handleRequest runs the command of a request and writes the response
+// to its reply pipe. Errors are reported to the sender, they don't
+// stop the daemon.
+func handleRequest(t *toggler, line string) {
+	var req request
+	err := json.Unmarshal([]byte(line), &req)
+	if err != nil {
+		log.Println("invalid request:", err)
+		return
+	}
+
+	var resp response
+	if len(req.Args) == 0 {
+		resp.Error = "empty request"
+	} else {
+		output, err := t.runCommand(req.Args)
+		resp.Output = output
+		if err != nil {
+			resp.Error = err.Error()
+		}
+	}
+
+	f, err := openReplyPipe(req.Reply)
+	if err != nil {
+		log.Printf("Open reply pipe (%s) error: %s", req.Reply, err)
+		return
+	}
+	defer f.Close()
+
+	err = json.NewEncoder(f).Encode(resp)
+	if err != nil {
+		log.Printf("Write reply pipe (%s) error: %s", req.Reply, err)
+	}
+}
+
+// openReplyPipe opens the reply pipe of a request for writing. Any
+// local user can write requests to the daemon's pipe, so the reply
+// must be a named pipe of our own, created by sendRequestToPipe;
+// anything else is refused rather than written to.
+func openReplyPipe(name string) (*os.File, error) {
+	base := filepath.Base(name)
+	if filepath.Dir(name) != filepath.Clean(os.TempDir()) || !strings.HasPrefix(base, replyPrefix) || !strings.HasSuffix(base, replySuffix) {
+		return nil, fmt.Errorf("not a reply pipe name")
+	}
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	err = checkReplyPipe(fi)
+	if err != nil {
+		return nil, err
+	}
+
+	// O_NONBLOCK fails instead of blocking when the sender is gone, and
+	// O_NOFOLLOW keeps the pipe from being swapped for a symlink
+	f, err := os.OpenFile(name, os.O_WRONLY|syscall.O_NONBLOCK|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err = f.Stat()
+	if err == nil {
+		err = checkReplyPipe(fi)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkReplyPipe checks that fi is a named pipe owned by the daemon's
+// user.
+func checkReplyPipe(fi os.FileInfo) error {
+	if fi.Mode()&os.ModeType != os.ModeNamedPipe {
+		return fmt.Errorf("not a named pipe")
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("not owned by the daemon's user")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOpenReplyPipe(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	other := t.TempDir()
+
+	mkfifo := func(name string) string {
+		p := filepath.Join(tmp, name)
+		if err := syscall.Mkfifo(p, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	pipe := mkfifo(replyPrefix + "1" + replySuffix)
+	misnamed := mkfifo("other.fifo")
+
+	file := filepath.Join(tmp, replyPrefix+"2"+replySuffix)
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(tmp, replyPrefix+"3"+replySuffix)
+	if err := os.Symlink(pipe, link); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(other, replyPrefix+"4"+replySuffix)
+	if err := syscall.Mkfifo(outside, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// keep a reader open, so opening the pipe for writing succeeds
+	r, err := os.OpenFile(pipe, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"reply pipe", pipe, false},
+		{"not cleaned", filepath.Join(tmp, "x", "..", filepath.Base(pipe)), false},
+		{"regular file", file, true},
+		{"symlink to pipe", link, true},
+		{"other name", misnamed, true},
+		{"other directory", outside, true},
+		{"traversal", filepath.Join(tmp, replyPrefix+"/../"+filepath.Base(misnamed)), true},
+		{"missing", filepath.Join(tmp, replyPrefix+"5"+replySuffix), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := openReplyPipe(tt.path)
+			if f != nil {
+				f.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("openReplyPipe(%q) error = %v, want error %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// toggleRPC lets iTerm2 key bindings toggle a target directly, with
+// the "Invoke Script Function" action:
+//
+//	iterm2_toggle(target: "vim")
+var toggleRPC = iterm2.RPC{
+	Name:      "iterm2_toggle",
+	Arguments: []string{"target"},
+}
+
+// registerRPC registers the toggle function with iTerm2 until ctx is
+// done.
+func (t *toggler) registerRPC(ctx context.Context) error {
+	return t.app.RegisterRPC(ctx, toggleRPC, func(args map[string]iterm2.Value) (any, error) {
+		arg := args["target"].String()
+		if arg == "" {
+			return nil, fmt.Errorf("missing target")
+		}
+		log.Println("received call for", arg)
+		err := t.handleArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		return arg, nil
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// statusCadence is how often iTerm2 asks for the status bar labels.
+const statusCadence = 2 * time.Second
+
+// statusMaxAge is how long computed status bar labels are reused.
+// Each session showing the component asks for its label, and finding
+// the sessions of all targets takes many requests, all while toggles
+// wait. Toggles and commands invalidate the labels, so this only
+// bounds how long other changes, such as closing a session by hand,
+// take to show.
+const statusMaxAge = 5 * statusCadence
+
+// statusComponent shows which targets a session belongs to and its
+// position in each target's cycle, such as "vim 2/4".
+var statusComponent = iterm2.StatusBarComponent{
+	RPC: iterm2.RPC{
+		Name:      "iterm2_toggle_status",
+		Arguments: []string{"session_id"},
+		Defaults:  map[string]string{"session_id": "id"},
+	},
+	Identifier:          "com.github.leonb.iterm2-toggle-session.status",
+	ShortDescription:    "Toggle Target",
+	DetailedDescription: "Shows the toggle target of the session and its position in the target's cycle.",
+	Exemplar:            "vim 2/4",
+	UpdateCadence:       statusCadence,
+}
+
+// statusLabels caches the status bar label of each session.
+type statusLabels struct {
+	mu     sync.Mutex
+	at     time.Time
+	labels map[string]string
+	// stale is set when the labels may have changed. It is separate
+	// from mu, as statusLabel holds mu while it waits for t.mu.
+	stale atomic.Bool
+}
+
+// invalidate makes the next status bar update compute the labels
+// again.
+func (l *statusLabels) invalidate() {
+	l.stale.Store(true)
+}
+
+// registerStatusBar registers the status bar component with iTerm2
+// until ctx is done.
+func (t *toggler) registerStatusBar(ctx context.Context) error {
+	return t.app.RegisterStatusBarComponent(ctx, statusComponent, func(args map[string]iterm2.Value) (any, error) {
+		return t.statusLabel(args["session_id"].String())
+	})
+}
+
+// statusLabel returns the status bar label of a session.
+func (t *toggler) statusLabel(id string) (string, error) {
+	t.status.mu.Lock()
+	defer t.status.mu.Unlock()
+
+	stale := t.status.stale.Swap(false)
+	if stale || t.status.labels == nil || time.Since(t.status.at) > statusMaxAge {
+		labels, err := t.statusLabels()
+		if err != nil {
+			return "", err
+		}
+		t.status.labels = labels
+		t.status.at = time.Now()
+	}
+	return t.status.labels[id], nil
+}
+
+// statusLabels returns the labels of all sessions that belong to a
+// target, keyed by session ID.
+func (t *toggler) statusLabels() (map[string]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := map[string][]string{}
+	for _, name := range t.targetNames() {
+		sessions, _, err := findSessions(t.app, t.config.target(name).Match)
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range sessions {
+			id := s.GetSessionID()
+			parts[id] = append(parts[id], fmt.Sprintf("%s %d/%d", name, i+1, len(sessions)))
+		}
+	}
+
+	labels := map[string]string{}
+	for id, p := range parts {
+		labels[id] = strings.Join(p, ", ")
+	}
+	return labels, nil
+}
+
+// targetNames returns the configured targets along with the ones that
+// were toggled without being configured, sorted by name. t.mu must be
+// held.
+func (t *toggler) targetNames() []string {
+	names := []string{}
+	for name := range t.config.Targets {
+		names = append(names, name)
+	}
+	for name := range t.toggled {
+		if _, ok := t.config.Targets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
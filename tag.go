@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// tagIdentity is the identity programs use in custom escape sequences
+// meant for the toggle.
+const tagIdentity = "iterm2-toggle"
+
+// tagVariable is the session variable that holds a session's tag. It
+// sticks to the session, also across daemon restarts.
+const tagVariable = "user.toggleTag"
+
+// watchTags tags sessions whose programs ask for it, until ctx is
+// done. A program tags its session by writing
+//
+//	OSC 1337 ; Custom=id=iterm2-toggle:<tag> ST
+//
+// for example with printf '\e]1337;Custom=id=iterm2-toggle:vim-main\a'.
+// An empty tag removes the session's tag. Tags are matched with the
+// "tag" match rule, and by the name of a target without match rules,
+// so toggling vim-main also finds the session above. Anything printed
+// in a session can tag it, so tags are only trusted that far; see
+// matcher.orTag.
+func (t *toggler) watchTags(ctx context.Context) error {
+	sequences, err := t.app.WatchCustomEscapeSequences(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for e := range sequences {
+			if e.Identity != tagIdentity {
+				continue
+			}
+			var tag any
+			if e.Payload != "" {
+				tag = e.Payload
+			}
+			log.Printf("tagging session %s with %q", e.Session.GetSessionID(), e.Payload)
+			t.mu.Lock()
+			err := e.Session.VariablesSet(map[string]any{tagVariable: tag})
+			t.mu.Unlock()
+			t.status.invalidate()
+			if err != nil {
+				log.Println("could not tag session", e.Session.GetSessionID(), err)
+			}
+		}
+		log.Println("stopped watching tags")
+	}()
+	return nil
+}
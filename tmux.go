@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// How long to wait for unhidden tmux windows to get a tab.
+const tmuxShowTimeout = 2 * time.Second
+
+// tmuxWindow is a window of a tmux integration connection.
+type tmuxWindow struct {
+	conn *iterm2.TmuxConnection
+	// id is tmux's window ID, such as "@1".
+	id      string
+	session string
+	name    string
+}
+
+// tmuxKey identifies a tmux window across tmux integration
+// connections, as window IDs are only unique within a tmux server.
+type tmuxKey struct {
+	conn string
+	id   string
+}
+
+// tmuxWindows holds the windows of all tmux integration connections.
+type tmuxWindows map[tmuxKey]tmuxWindow
+
+// withID returns the windows with the given window ID, one for each
+// connection that has it.
+func (ws tmuxWindows) withID(id string) []tmuxWindow {
+	list := []tmuxWindow{}
+	for key, w := range ws {
+		if key.id == id {
+			list = append(list, w)
+		}
+	}
+	return list
+}
+
+// listTmuxWindows returns the windows of all tmux integration
+// connections. Windows of different tmux servers may have the same
+// ID, which is logged: sessions only know the ID of their window, so
+// they can't be told apart.
+func listTmuxWindows(app *iterm2.App) (tmuxWindows, error) {
+	conns, err := app.TmuxConnections()
+	if err != nil {
+		return nil, err
+	}
+	windows := tmuxWindows{}
+	connOf := map[string]string{}
+	for _, conn := range conns {
+		out, err := conn.SendCommand("list-windows -F '#{window_id}\t#{session_name}\t#{window_name}'")
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			fields := strings.SplitN(line, "\t", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			id := fields[0]
+			if other, ok := connOf[id]; ok {
+				log.Printf("tmux window %s exists on connections %s and %s", id, other, conn.GetConnectionID())
+			}
+			connOf[id] = conn.GetConnectionID()
+			key := tmuxKey{conn: conn.GetConnectionID(), id: id}
+			windows[key] = tmuxWindow{conn: conn, id: id, session: fields[1], name: fields[2]}
+		}
+	}
+	return windows, nil
+}
+
+// tmuxWindowOf returns the ID of the tmux window the session is in,
+// or "" if it isn't in one.
+func tmuxWindowOf(s *iterm2.Session) (string, error) {
+	vars, err := s.VariablesGet([]string{"tab.tmuxWindow"})
+	if err != nil {
+		return "", err
+	}
+	n, err := vars["tab.tmuxWindow"].Int()
+	if err != nil || n < 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("@%d", n), nil
+}
+
+// visibleTmuxWindows returns the IDs of the tmux windows that have a
+// tab. An ID shared by windows of different connections counts as
+// visible for all of them.
+func visibleTmuxWindows(app *iterm2.App) (map[string]bool, error) {
+	sessions, _, err := findSessions(app, matcher{})
+	if err != nil {
+		return nil, err
+	}
+	visible := map[string]bool{}
+	for _, s := range sessions {
+		id, err := tmuxWindowOf(s)
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			visible[id] = true
+		}
+	}
+	return visible, nil
+}
+
+// prepareTmux makes the tmux windows of a target visible, creating one
+// if there are none and the target launches in tmux. It returns all
+// tmux windows, for matching. tmux requests can't be made during a
+// transaction, so this runs before the toggle's transaction.
+func (t *toggler) prepareTmux(arg string, target target) (tmuxWindows, error) {
+	windows, err := listTmuxWindows(t.app)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []tmuxWindow{}
+	for _, w := range windows {
+		if target.Match.matchTmux(w) {
+			matching = append(matching, w)
+		}
+	}
+
+	if len(matching) == 0 {
+		if target.Launch == nil || !target.Launch.Tmux {
+			return windows, nil
+		}
+		err := t.launchTmux(arg, target)
+		if err != nil {
+			return nil, err
+		}
+		return listTmuxWindows(t.app)
+	}
+
+	visible, err := visibleTmuxWindows(t.app)
+	if err != nil {
+		return nil, err
+	}
+	hidden := map[string]bool{}
+	for _, w := range matching {
+		if visible[w.id] {
+			continue
+		}
+		log.Println("showing tmux window", w.id, w.name)
+		err := w.conn.SetWindowVisible(w.id, true)
+		if err != nil {
+			return nil, err
+		}
+		hidden[w.id] = true
+	}
+
+	// iTerm2 opens the tabs of shown windows asynchronously
+	deadline := time.Now().Add(tmuxShowTimeout)
+	for len(hidden) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		visible, err := visibleTmuxWindows(t.app)
+		if err != nil {
+			return nil, err
+		}
+		for id := range hidden {
+			if visible[id] {
+				delete(hidden, id)
+			}
+		}
+	}
+	return windows, nil
+}
+
+// launchTmux creates a tmux window for the target named arg, in the
+// target's tmux session, and names it after the target's tmux window.
+func (t *toggler) launchTmux(arg string, target target) error {
+	windows, err := listTmuxWindows(t.app)
+	if err != nil {
+		return err
+	}
+	var conn *iterm2.TmuxConnection
+	for _, w := range windows {
+		if target.Match.TmuxSession == "" || w.session == target.Match.TmuxSession {
+			conn = w.conn
+			break
+		}
+	}
+	if conn == nil {
+		return fmt.Errorf("no tmux integration connection for target %q", arg)
+	}
+
+	affinity := ""
+	if target.Launch.Tab {
+		focus, err := t.app.CurrentFocus()
+		if err != nil {
+			return err
+		}
+		if focus.Window != nil {
+			affinity = focus.Window.GetWindowID()
+		}
+	}
+
+	log.Println("launching tmux window for", arg)
+	tab, err := conn.CreateWindow(affinity)
+	if err != nil {
+		return err
+	}
+	sessions, err := tab.ListSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("launched tab %q has no sessions", tab.GetTabID())
+	}
+	s := sessions[0]
+
+	if target.Match.TmuxWindow != "" {
+		id, err := tmuxWindowOf(s)
+		if err != nil {
+			return err
+		}
+		_, err = conn.SendCommand(fmt.Sprintf("rename-window -t %s %s", id, tmuxQuote(target.Match.TmuxWindow)))
+		if err != nil {
+			return err
+		}
+	}
+
+	if target.Launch.Command != "" {
+		return s.SendText(target.Launch.Command + "\n")
+	}
+	return nil
+}
+
+// tmuxQuote quotes s as a single argument of a tmux command.
+func tmuxQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LeonB/iterm2-toggle-session/iterm2"
+)
+
+// How long to wait for the sessions of a restored arrangement to match.
+const arrangementTimeout = 2 * time.Second
+
+// toggler handles toggle arguments. It keeps state between toggles,
+// so a single toggler is used for the lifetime of the daemon.
+type toggler struct {
+	app    *iterm2.App
+	config *config
+
+	// mu serializes everything that makes requests to iTerm2: toggles,
+	// which arrive both from the named pipe and from iTerm2 itself,
+	// commands, status bar updates, tagging and restoring sessions
+	// after a highlight or flash. A toggle's transaction would
+	// otherwise take in the requests of whatever else is running.
+	mu sync.Mutex
+
+	// previousApp is the bundle identifier of the macOS app that was
+	// frontmost before the toggle last brought iTerm2 forward.
+	previousApp string
+
+	// recent orders sessions by when they were last active.
+	recent mru
+
+	// toggled holds the arguments that were toggled, guarded by mu.
+	toggled map[string]bool
+
+	// status caches the labels of the status bar component.
+	status statusLabels
+
+	// highlights tracks sessions whose colors are to be restored.
+	highlights highlights
+
+	// flashes tracks sessions whose badges are to be restored.
+	flashes flashes
+}
+
+// action is what a toggle does, as decided by decide.
+type action int
+
+const (
+	// actionActivate activates one of the matching sessions.
+	actionActivate action = iota
+	// actionHide hides iTerm2.
+	actionHide
+	// actionToggleBack reactivates the previously frontmost app.
+	actionToggleBack
+)
+
+func (a action) String() string {
+	switch a {
+	case actionActivate:
+		return "activate"
+	case actionHide:
+		return "hide"
+	case actionToggleBack:
+		return "toggle-back"
+	}
+	return "unknown"
+}
+
+// decide picks the action for a toggle. currentIndex is the index of
+// the focused session among the count matching sessions, or -1 if the
+// focused session does not match. appActive tells whether iTerm2 is
+// the frontmost app. For actionActivate it also returns the index of
+// the session to activate.
+func decide(onFocused string, appActive bool, currentIndex, count int) (action, int) {
+	if currentIndex < 0 {
+		return actionActivate, 0
+	}
+
+	next := (currentIndex + 1) % count
+	switch onFocused {
+	case onFocusedHide:
+		if !appActive {
+			return actionActivate, currentIndex
+		}
+		return actionHide, currentIndex
+	case onFocusedToggleBack:
+		if !appActive {
+			return actionActivate, currentIndex
+		}
+		return actionToggleBack, currentIndex
+	}
+	return actionActivate, next
+}
+
+func (t *toggler) handleArg(arg string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.status.invalidate()
+
+	if t.toggled == nil {
+		t.toggled = map[string]bool{}
+	}
+	t.toggled[arg] = true
+
+	target := t.config.target(arg)
+
+	if target.Match.usesTmux() {
+		windows, err := t.prepareTmux(arg, target)
+		if err != nil {
+			return err
+		}
+		target.Match.tmux = windows
+	}
+
+	if target.Arrangement != "" {
+		err := t.prepareArrangement(target)
+		if err != nil {
+			return err
+		}
+	}
+
+	// look up and activate the session in one go, so sessions can't
+	// close or move in between. Hiding iTerm2 waits for the transaction
+	// to end, as it needs iTerm2's main loop.
+	var act action
+	err := t.app.Transaction(func(app *iterm2.App) error {
+		var err error
+		act, err = t.toggle(app, arg, target)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	switch act {
+	case actionHide:
+		return hideIterm()
+	case actionToggleBack:
+		if t.previousApp == "" {
+			log.Println("no previous app known, hiding iTerm2")
+			return hideIterm()
+		}
+		log.Println("activating previous app", t.previousApp)
+		return activateApp(t.previousApp)
+	}
+	return nil
+}
+
+// prepareArrangement restores the target's arrangement when no
+// sessions match, and waits for the restored sessions to match. Their
+// jobs only start once iTerm2's main loop runs, so this runs before
+// the toggle's transaction.
+func (t *toggler) prepareArrangement(target target) error {
+	sessions, _, err := findSessions(t.app, target.Match)
+	if err != nil || len(sessions) > 0 {
+		return err
+	}
+
+	log.Println("no matching sessions found, restoring arrangement", target.Arrangement)
+	err = t.app.RestoreArrangement(target.Arrangement)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(arrangementTimeout)
+	for len(sessions) == 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		sessions, _, err = findSessions(t.app, target.Match)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toggle finds the sessions of the target named arg, launching one if
+// needed, and activates the next one. It returns the action it decided
+// on; hiding iTerm2 and toggling back are left to the caller.
+func (t *toggler) toggle(app *iterm2.App, arg string, target target) (action, error) {
+	focus, err := app.CurrentFocus()
+	if err != nil {
+		return 0, err
+	}
+
+	currentSession := ""
+	if focus.Session != nil {
+		currentSession = focus.Session.GetSessionID()
+	}
+
+	sessions, windowOf, err := findSessions(app, target.Match)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(sessions) == 0 {
+		log.Println("no matching sessions found")
+		// tmux windows are launched before the transaction
+		if target.Launch == nil || target.Launch.Tmux {
+			return actionActivate, nil
+		}
+		s, w, err := t.launch(arg, *target.Launch, focus)
+		if err != nil {
+			return 0, err
+		}
+		sessions = append(sessions, s)
+		windowOf[s.GetSessionID()] = w
+	}
+
+	// get index of current session
+	currentIndex := -1
+	for i, s := range sessions {
+		if s.GetSessionID() == currentSession {
+			currentIndex = i
+			break
+		}
+	}
+
+	log.Println("current index", currentIndex)
+
+	act, index := decide(target.OnFocused, focus.AppActive, currentIndex, len(sessions))
+	log.Println("action", act)
+
+	if act != actionActivate {
+		return act, nil
+	}
+
+	opts := target.Activate.activateOptions()
+
+	// remember which app to go back to, before iTerm2 takes its place
+	if opts.ActivateApp && !focus.AppActive {
+		id, err := frontmostApp()
+		if err != nil {
+			log.Println(err)
+		} else if id != itermBundleID {
+			t.previousApp = id
+		}
+	}
+
+	next := sessions[index]
+	log.Println("next", next.GetSessionID())
+
+	// activate the session, and the app along with it
+	log.Printf("activating session %s with %+v", next.GetSessionID(), opts)
+	err = next.ActivateWithOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if target.Restart {
+		err = next.Restart(true)
+		if err == nil {
+			log.Printf("restarted session %s", next.GetSessionID())
+		} else if !errors.Is(err, iterm2.ErrNotRestartable) {
+			return 0, err
+		}
+	}
+
+	if target.Highlight != nil {
+		log.Printf("highlighting session %s", next.GetSessionID())
+		err = t.highlight(next, target.Highlight)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if target.Flash != nil {
+		log.Printf("flashing session %s", next.GetSessionID())
+		err = t.flash(next, target.Flash)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if target.Frame != nil {
+		w := windowOf[next.GetSessionID()]
+		log.Printf("moving window %s to %+v", w.GetWindowID(), *target.Frame)
+		err = w.SetFrame(*target.Frame)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return act, nil
+}
+
+// launch creates a session for the target named arg as described by
+// l and returns it, along with its window.
+func (t *toggler) launch(arg string, l launch, focus *iterm2.Focus) (*iterm2.Session, *iterm2.Window, error) {
+	opts := iterm2.CreateTabOptions{
+		ProfileName: l.Profile,
+		Command:     l.Command,
+	}
+
+	var (
+		tab *iterm2.Tab
+		w   *iterm2.Window
+	)
+	if l.Tab && focus.Window != nil {
+		w = focus.Window
+		log.Println("launching tab in window", w.GetWindowID())
+		created, err := w.CreateTabWithOptions(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		tab = created
+	} else {
+		log.Println("launching window")
+		created, err := t.app.CreateWindowWithOptions(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = created
+		tabs, err := w.ListTabs()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(tabs) == 0 {
+			return nil, nil, fmt.Errorf("launched window %q has no tabs", w.GetWindowID())
+		}
+		tab = tabs[0]
+	}
+
+	title := arg
+	if l.Title != nil {
+		title = *l.Title
+	}
+	if title != "" {
+		err := tab.SetTitle(title)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sessions, err := tab.ListSessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil, fmt.Errorf("launched tab %q has no sessions", tab.GetTabID())
+	}
+	return sessions[0], w, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDecide(t *testing.T) {
+	const count = 3
+	tests := []struct {
+		onFocused    string
+		appActive    bool
+		currentIndex int
+		want         action
+		wantIndex    int
+	}{
+		// nothing focused matches: activate the first session
+		{onFocusedCycle, true, -1, actionActivate, 0},
+		{onFocusedCycle, false, -1, actionActivate, 0},
+		{onFocusedHide, true, -1, actionActivate, 0},
+		{onFocusedHide, false, -1, actionActivate, 0},
+		{onFocusedToggleBack, true, -1, actionActivate, 0},
+		{onFocusedToggleBack, false, -1, actionActivate, 0},
+
+		// cycle activates the next session, wrapping around
+		{onFocusedCycle, true, 1, actionActivate, 2},
+		{onFocusedCycle, true, 2, actionActivate, 0},
+		{onFocusedCycle, false, 1, actionActivate, 2},
+		{onFocusedCycle, false, 2, actionActivate, 0},
+
+		// hide and toggle-back only apply when iTerm2 is frontmost,
+		// otherwise the focused session is brought forward
+		{onFocusedHide, true, 1, actionHide, 1},
+		{onFocusedHide, true, 2, actionHide, 2},
+		{onFocusedHide, false, 1, actionActivate, 1},
+		{onFocusedHide, false, 2, actionActivate, 2},
+		{onFocusedToggleBack, true, 1, actionToggleBack, 1},
+		{onFocusedToggleBack, true, 2, actionToggleBack, 2},
+		{onFocusedToggleBack, false, 1, actionActivate, 1},
+		{onFocusedToggleBack, false, 2, actionActivate, 2},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s/active=%t/index=%d", tt.onFocused, tt.appActive, tt.currentIndex)
+		t.Run(name, func(t *testing.T) {
+			got, index := decide(tt.onFocused, tt.appActive, tt.currentIndex, count)
+			if got != tt.want || index != tt.wantIndex {
+				t.Errorf("decide() = %s, %d, want %s, %d", got, index, tt.want, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestDecideSingleSession(t *testing.T) {
+	// cycling a single session wraps around to itself
+	got, index := decide(onFocusedCycle, true, 0, 1)
+	if got != actionActivate || index != 0 {
+		t.Errorf("decide() = %s, %d, want %s, 0", got, index, actionActivate)
+	}
+}